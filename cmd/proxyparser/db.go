@@ -0,0 +1,240 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"ProxyParserGO/pkg/models"
+	"ProxyParserGO/pkg/output"
+	"ProxyParserGO/pkg/store"
+)
+
+const dbUsage = `Usage: proxyparser db <command> [flags]
+
+Commands:
+  list    show stored proxies ranked by reliability score
+  prune   delete proxies that have not been seen or checked recently
+  export  write the top-N proxies in any output format
+
+Run "proxyparser db <command> -h" for the flags of each command.
+`
+
+// runDB implements the db command, which inspects and maintains the proxy
+// database written by checking runs.
+func runDB(args []string) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, dbUsage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch args[0] {
+	case "list":
+		err = runDBList(args[1:])
+	case "prune":
+		err = runDBPrune(args[1:])
+	case "export":
+		err = runDBExport(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown db command: %q\n\n%s", args[0], dbUsage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// recordFilter selects database records by the flags shared by list and
+// export.
+type recordFilter struct {
+	protocol  string
+	source    string
+	minUptime float64
+	minChecks int
+
+	capabilities string
+	required     []models.Capability
+	target       string
+}
+
+func (f *recordFilter) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.protocol, "type", "", "Only proxies working with this protocol: http, socks4, socks5, socks4a, socks5h")
+	fs.StringVar(&f.source, "source", "", "Only proxies found in this source")
+	fs.Float64Var(&f.minUptime, "min-uptime", 0, "Minimum fraction of successful checks (0-1)")
+	fs.IntVar(&f.minChecks, "min-checks", 0, "Minimum number of recorded checks")
+	fs.StringVar(&f.target, "target", "", "Only proxies whose last check against this target (name from -targets) passed")
+	fs.StringVar(&f.capabilities, "capabilities", "", "Only HTTP proxies probed with these comma-separated capabilities: forward, https, connect")
+}
+
+func (f *recordFilter) match(r store.Record) bool {
+	// Detected SOCKS variants count, so socks5h also matches socks5 proxies
+	// found to resolve remotely
+	protocol := models.Protocol(f.protocol)
+	if f.protocol != "" && r.Proxy.Protocol != protocol && !slices.Contains(r.Proxy.Variants, protocol) {
+		return false
+	}
+	if f.source != "" && !slices.Contains(r.Sources, f.source) {
+		return false
+	}
+	if len(f.required) > 0 && (r.Proxy.Protocol != models.HTTP || !r.Proxy.Can(f.required...)) {
+		return false
+	}
+	if f.target != "" && !r.WorksFor(f.target) {
+		return false
+	}
+	if len(r.Checks) < f.minChecks {
+		return false
+	}
+	return r.Uptime() >= f.minUptime
+}
+
+// loadRecords returns the n best-scoring records in the database at path
+// that match filter.
+func loadRecords(path string, filter recordFilter, n int) ([]store.Record, error) {
+	if filter.protocol != "" {
+		protocol, err := models.ParseProtocol(filter.protocol)
+		if err != nil {
+			return nil, err
+		}
+		filter.protocol = string(protocol)
+	}
+	if filter.capabilities != "" {
+		caps, err := models.ParseCapabilities(filter.capabilities)
+		if err != nil {
+			return nil, err
+		}
+		filter.required = caps
+	}
+
+	db, err := openDB(path)
+	if err != nil {
+		return nil, err
+	}
+	records, err := db.All()
+	db.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []store.Record
+	for _, r := range records {
+		if filter.match(r) {
+			matched = append(matched, r)
+		}
+	}
+	return store.Top(matched, n, time.Now()), nil
+}
+
+// openDB opens an existing database, refusing to create an empty one.
+func openDB(path string) (*store.Store, error) {
+	if path == "" {
+		return nil, errors.New("-db is required")
+	}
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	return store.Open(path)
+}
+
+func runDBList(args []string) error {
+	fs := flag.NewFlagSet("db list", flag.ExitOnError)
+	path := fs.String("db", "", "Database file, as written by a run with -db")
+	limit := fs.Int("limit", 50, "Number of proxies to show (0 = all)")
+	var filter recordFilter
+	filter.register(fs)
+	fs.Parse(args)
+
+	records, err := loadRecords(*path, filter, *limit)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROXY\tSCORE\tUPTIME\tCHECKS\tLATENCY\tLAST CHECK\tFIRST SEEN\tSOURCES")
+	for _, r := range records {
+		lastCheck := "never"
+		if c, ok := r.LastCheck(); ok {
+			status := "ok"
+			if !c.OK {
+				status = c.Failure
+			}
+			lastCheck = fmt.Sprintf("%s ago (%s)", now.Sub(c.Time).Round(time.Second), status)
+		}
+		fmt.Fprintf(tw, "%s\t%.3f\t%.0f%%\t%d\t%s\t%s\t%s\t%s\n",
+			r.Proxy.String(),
+			r.Score(now),
+			r.Uptime()*100,
+			len(r.Checks),
+			r.AvgLatency().Round(time.Millisecond),
+			lastCheck,
+			r.FirstSeen.Format("2006-01-02 15:04"),
+			strings.Join(r.Sources, ","),
+		)
+	}
+	return tw.Flush()
+}
+
+func runDBPrune(args []string) error {
+	fs := flag.NewFlagSet("db prune", flag.ExitOnError)
+	path := fs.String("db", "", "Database file, as written by a run with -db")
+	olderThan := fs.Duration("older-than", 7*24*time.Hour, "Delete proxies not seen or checked for this long")
+	fs.Parse(args)
+
+	db, err := openDB(*path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	removed, err := db.Prune(time.Now().Add(-*olderThan))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Removed %d proxies not seen or checked in the last %s\n", removed, olderThan)
+	return nil
+}
+
+func runDBExport(args []string) error {
+	fs := flag.NewFlagSet("db export", flag.ExitOnError)
+	path := fs.String("db", "", "Database file, as written by a run with -db")
+	top := fs.Int("top", 100, "Number of best-scoring proxies to export (0 = all)")
+	file := fs.String("file", "top_proxies.txt", "Output file")
+	formatName := fs.String("format", "plain", "Output format: "+strings.Join(output.Formats(), ", "))
+	var filter recordFilter
+	filter.register(fs)
+	fs.Parse(args)
+
+	records, err := loadRecords(*path, filter, *top)
+	if err != nil {
+		return err
+	}
+
+	out, err := openOutput(*file, *formatName)
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		p := r.Proxy
+		p.Latency = r.AvgLatency()
+		if len(r.Sources) > 0 {
+			p.Source = strings.Join(r.Sources, ",")
+		}
+		if err := out.Write(p); err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d proxies to %s\n", len(records), *file)
+	return nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"ProxyParserGO/pkg/checker"
+	"ProxyParserGO/pkg/fetcher"
+	"ProxyParserGO/pkg/models"
+	"ProxyParserGO/pkg/output"
+)
+
+// Exit codes used in headless mode.
+const (
+	exitOK          = 0
+	exitError       = 1
+	exitNoProxies   = 2   // run completed but found no valid proxies
+	exitInterrupted = 130 // stopped by SIGINT/SIGTERM
+)
+
+// progressInterval is how often headless mode reports checking progress.
+const progressInterval = 5 * time.Second
+
+// eventLog writes one progress event per line, as text or JSON.
+type eventLog struct {
+	mu   sync.Mutex
+	w    io.Writer
+	json bool
+}
+
+func (l *eventLog) emit(event string, fields map[string]any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if l.json {
+		record := map[string]any{"time": now.Format(time.RFC3339), "event": event}
+		for k, v := range fields {
+			record[k] = v
+		}
+		data, _ := json.Marshal(record)
+		fmt.Fprintln(l.w, string(data))
+		return
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s", now.Format("2006-01-02 15:04:05"), event)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%v", k, fields[k])
+	}
+	fmt.Fprintln(l.w, b.String())
+}
+
+// runHeadless runs fetch, dedupe and check without a TUI and returns the
+// process exit code.
+func runHeadless(opts checkOptions, fetchers []fetcher.Fetcher, out output.Writer) int {
+	var events *eventLog
+	switch *logFormat {
+	case "text", "json":
+		events = &eventLog{w: os.Stderr, json: *logFormat == "json"}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown log format: %q\n", *logFormat)
+		return exitError
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := time.Now()
+
+	dedup := newDeduper(*proxyType)
+	restored, remaining := opts.checkpoint.restore(dedup, out)
+
+	pool := startChecking(ctx, opts)
+	opts.checkpoint.start(checkpointInterval)
+	events.emit("checking", map[string]any{"threads": opts.threads})
+
+	// Fetch, queueing new proxies for checking as they arrive
+	var fetched, unique atomic.Int64
+	fetchDone := make(chan struct{})
+	go func() {
+		logger := func(msg string) {
+			events.emit("log", map[string]any{"msg": msg})
+		}
+
+		if *resume {
+			events.emit("resumed", map[string]any{"valid": len(restored), "remaining": len(remaining)})
+			unique.Add(int64(len(remaining)))
+			pool.Submit(remaining)
+		}
+
+		onProxies := func(proxies []models.Proxy) {
+			if err := opts.recordSeen(proxies); err != nil {
+				logger(fmt.Sprintf("Database error: %v", err))
+			}
+			fresh := dedup.Add(proxies)
+			opts.checkpoint.addFetched(fresh)
+			fetched.Add(int64(len(proxies)))
+			unique.Add(int64(len(fresh)))
+			pool.Submit(fresh)
+		}
+
+		if opts.checkpoint.fetchDone() {
+			logger("Fetching finished before the interruption, skipping sources")
+		} else {
+			runFetchers(ctx, fetchers, logger, onProxies)
+			if ctx.Err() == nil {
+				opts.checkpoint.setFetchDone()
+			}
+		}
+		pool.Close()
+		close(fetchDone)
+	}()
+
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+
+	checked, valid := 0, len(restored)
+	failures := make(map[checker.FailureKind]int)
+	limitReached := false
+
+	onValid := func(p models.Proxy) {
+		if limitReached {
+			return
+		}
+		writeProxy(out, p)
+		valid++
+		events.emit("valid", map[string]any{
+			"proxy":      p.String(),
+			"source":     p.Source,
+			"latency_ms": p.Latency.Milliseconds(),
+		})
+		if *proxyLimit > 0 && valid >= *proxyLimit {
+			limitReached = true
+			cancel()
+		}
+	}
+	onProgress := func(kind checker.FailureKind) {
+		checked++
+		if kind != checker.FailureNone {
+			failures[kind]++
+		}
+	}
+
+loop:
+	for {
+		select {
+		case p := <-checkResults:
+			onValid(p)
+		case kind := <-checkProgress:
+			onProgress(kind)
+		case <-fetchDone:
+			fetchDone = nil
+			events.emit("fetched", map[string]any{"fetched": fetched.Load(), "unique": unique.Load()})
+		case <-ticker.C:
+			events.emit("progress", map[string]any{"checked": checked, "total": unique.Load(), "valid": valid})
+		case <-checkDone:
+			break loop
+		}
+	}
+
+	// Workers are done, but results may still be buffered
+drain:
+	for {
+		select {
+		case p := <-checkResults:
+			onValid(p)
+		case kind := <-checkProgress:
+			onProgress(kind)
+		default:
+			break drain
+		}
+	}
+
+	interrupted := ctx.Err() != nil && !limitReached
+	if err := opts.checkpoint.finish(!interrupted); err != nil {
+		events.emit("log", map[string]any{"msg": fmt.Sprintf("Error saving %s: %v", *statePath, err)})
+	}
+	summary := map[string]any{
+		"checked":   checked,
+		"valid":     valid,
+		"total":     unique.Load(),
+		"elapsed_s": int(time.Since(start).Seconds()),
+		"file":      *outputFile,
+	}
+	for kind, n := range failures {
+		summary["failed_"+string(kind)] = n
+	}
+
+	switch {
+	case interrupted:
+		events.emit("interrupted", summary)
+		return exitInterrupted
+	case valid == 0:
+		events.emit("done", summary)
+		return exitNoProxies
+	}
+	events.emit("done", summary)
+	return exitOK
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"ProxyParserGO/pkg/fetcher"
+	"ProxyParserGO/pkg/models"
+)
+
+// listFlag collects the values of a repeatable string flag.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// parseListLine parses a line of a proxy list. Besides the forms accepted by
+// models.ParseProxy it reads the JSON lines written by -format jsonl.
+func parseListLine(line string, defaultProtocol models.Protocol) (models.Proxy, error) {
+	if !strings.HasPrefix(line, "{") {
+		return models.ParseProxy(line, defaultProtocol)
+	}
+
+	var rec struct {
+		IP       string `json:"ip"`
+		Port     string `json:"port"`
+		Protocol string `json:"protocol"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+	if err := json.Unmarshal([]byte(line), &rec); err != nil {
+		return models.Proxy{}, err
+	}
+	protocol := defaultProtocol
+	if rec.Protocol != "" {
+		p, err := models.ParseProtocol(rec.Protocol)
+		if err != nil {
+			return models.Proxy{}, err
+		}
+		protocol = p
+	}
+	if rec.IP == "" || rec.Port == "" || protocol == "" {
+		return models.Proxy{}, fmt.Errorf("incomplete proxy record: %s", line)
+	}
+	return models.Proxy{IP: rec.IP, Port: rec.Port, Protocol: protocol, Username: rec.Username, Password: rec.Password}, nil
+}
+
+// readProxyList parses one proxy per line, skipping blanks and # comments.
+// It returns the proxies and how many lines could not be parsed.
+func readProxyList(r io.Reader, defaultProtocol models.Protocol, source string) ([]models.Proxy, int, error) {
+	var proxies []models.Proxy
+	skipped := 0
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p, err := parseListLine(line, defaultProtocol)
+		if err != nil {
+			skipped++
+			continue
+		}
+		p.Source = source
+		proxies = append(proxies, p)
+	}
+
+	return proxies, skipped, scanner.Err()
+}
+
+// inputFetcher reads a local proxy list, or stdin for "-", so existing lists
+// can be re-checked without contacting any online source.
+type inputFetcher struct {
+	Path     string
+	Protocol models.Protocol
+}
+
+func (f *inputFetcher) Fetch(ctx context.Context, logger fetcher.Logger, onProxies fetcher.ProxyCallback) error {
+	name := f.Path
+	r := io.Reader(os.Stdin)
+	if f.Path == "-" {
+		name = "stdin"
+	} else {
+		file, err := os.Open(f.Path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	proxies, skipped, err := readProxyList(r, f.Protocol, name)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", name, err)
+	}
+
+	if len(proxies) > 0 && onProxies != nil {
+		onProxies(proxies)
+	}
+	if logger != nil {
+		logger(fmt.Sprintf("Read %d proxies from %s (%d lines skipped)", len(proxies), name, skipped))
+	}
+	return nil
+}
+
+// inputFetchers builds fetchers for the -input flags. It reports whether
+// stdin is among them, since the TUI cannot share stdin with the list.
+func inputFetchers(paths []string, defaultProtocol string) ([]fetcher.Fetcher, bool, error) {
+	var protocol models.Protocol
+	if defaultProtocol != "" {
+		p, err := models.ParseProtocol(defaultProtocol)
+		if err != nil {
+			return nil, false, err
+		}
+		protocol = p
+	}
+
+	var fetchers []fetcher.Fetcher
+	usesStdin := false
+	for _, path := range paths {
+		if path == "-" {
+			if usesStdin {
+				return nil, false, fmt.Errorf("stdin given more than once")
+			}
+			usesStdin = true
+		}
+		fetchers = append(fetchers, &inputFetcher{Path: path, Protocol: protocol})
+	}
+	return fetchers, usesStdin, nil
+}
+
+// checkNotOutput refuses inputs that are the output file, which is
+// truncated before the inputs are read.
+func checkNotOutput(paths []string, outputPath string) error {
+	out, err := os.Stat(outputPath)
+	if err != nil {
+		return nil
+	}
+	for _, path := range paths {
+		if path == "-" {
+			continue
+		}
+		in, err := os.Stat(path)
+		if err == nil && os.SameFile(in, out) {
+			return fmt.Errorf("-input %s is also the output file; pass a different -file", path)
+		}
+	}
+	return nil
+}
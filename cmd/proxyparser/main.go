@@ -1,9 +1,15 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"math/rand/v2"
+	"net/http"
 	"os"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -11,45 +17,177 @@ import (
 
 	"ProxyParserGO/pkg/checker"
 	"ProxyParserGO/pkg/fetcher"
+	"ProxyParserGO/pkg/gateway"
+	"ProxyParserGO/pkg/judge"
 	"ProxyParserGO/pkg/models"
+	"ProxyParserGO/pkg/output"
+	"ProxyParserGO/pkg/store"
 
 	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/mattn/go-isatty"
 )
 
 // Flags
 var (
-	proxyLimit  = flag.Int("proxy", 0, "Number of valid proxies to find (0 = no limit)")
-	proxyType   = flag.String("type", "", "Type of proxy: http, socks4, socks5")
-	validations = flag.Int("validations", 1, "Number of times to validate each proxy")
-	outputFile  = flag.String("file", "valid_proxies.txt", "Output file for valid proxies")
-	threads     = flag.Int("threads", 10, "Number of concurrent threads")
-	timeout     = flag.Int("timeout", 10, "Timeout in seconds for checking")
-	checkURL    = flag.String("check-url", "https://www.google.com", "URL to use for checking proxy connectivity")
-	debug       = flag.Bool("debug", false, "Enable debug logging to debug.txt")
+	proxyLimit   = flag.Int("proxy", 0, "Number of valid proxies to find (0 = no limit)")
+	proxyType    = flag.String("type", "", "Type of proxy: http, socks4, socks5, or socks4a, socks5h to check SOCKS proxies with remote DNS")
+	validations  = flag.Int("validations", 1, "Number of times to validate each proxy against each target")
+	outputFile   = flag.String("file", "valid_proxies.txt", "Output file for valid proxies")
+	format       = flag.String("format", "plain", "Output format: "+strings.Join(output.Formats(), ", "))
+	threads      = flag.Int("threads", 10, "Number of concurrent threads")
+	timeout      = flag.Int("timeout", 10, "Timeout in seconds for checking")
+	checkURL     = flag.String("check-url", "https://www.google.com", "URL to use for checking proxy connectivity")
+	debug        = flag.Bool("debug", false, "Enable debug logging to debug.txt")
+	sourcesFile  = flag.String("sources", "", "YAML/JSON file listing proxy sources (default: built-in list)")
+	judgeURL     = flag.String("judge-url", "", "Proxy judge URL used to detect anonymity (see the judge command)")
+	anonymity    = flag.String("anonymity", "", "Comma-separated anonymity levels to keep: transparent, anonymous, elite (requires -judge-url)")
+	headless     = flag.Bool("headless", false, "Run without the TUI (default when stdout is not a terminal)")
+	logFormat    = flag.String("log-format", "text", "Headless progress format on stderr: text or json")
+	inputProto   = flag.String("input-protocol", "http", "Protocol for -input lines without a scheme: http, socks4, socks5, socks4a, socks5h")
+	statePath    = flag.String("state", "", "File run progress is checkpointed to, for -resume")
+	resume       = flag.Bool("resume", false, "Resume the interrupted run saved in -state, checking only the proxies left")
+	dbPath       = flag.String("db", "", "Database file recording every proxy and check across runs (see the db command)")
+	userAgent    = flag.String("user-agent", "", "User-Agent sent to proxy sources")
+	fetchRetry   = flag.Int("fetch-retries", fetcher.DefaultRetries, "Retries of source requests failing with a network error, 429 or 5xx (-1 to disable)")
+	fetchRate    = flag.Float64("fetch-rate", 0, "Requests per second to each source host (0 = no limit)")
+	fetchConns   = flag.Int("fetch-concurrency", 0, "Concurrent requests to each source host (0 = no limit)")
+	cacheDir     = flag.String("cache", "", "Directory caching source downloads for conditional requests and -offline")
+	cacheRefresh = flag.Duration("cache-refresh", 0, "Reuse cached source pages without a request until they are this old (sources may override with refresh)")
+	offline      = flag.Bool("offline", false, "Fetch sources only from -cache, without network requests")
+	fetchProxy   = flag.String("fetch-proxy", "", `Proxy URL sources are fetched through, or "pool" for -fetch-pool (sources may override with proxy)`)
+	fetchPool    = flag.String("fetch-pool", "", `Validated proxies that "pool" sources rotate through: a proxy list file, or "db" for the best in -db`)
+	poolSize     = flag.Int("fetch-pool-size", 20, "Number of proxies taken into -fetch-pool")
+	detectSOCKS  = flag.Bool("detect-socks", false, "Check SOCKS proxies with local and remote DNS (socks4a, socks5h) and keep the variants that work")
+	probeHTTP    = flag.Bool("probe-http", false, "Probe working HTTP proxies for forwarding, CONNECT to port 443 and CONNECT to other ports")
+	capabilities = flag.String("capabilities", "", "Comma-separated capabilities HTTP proxies must have: forward, https, connect (requires -probe-http)")
+	expectBody   = flag.String("expect-body", "", "Text the -check-url response body must contain")
+	expectRegex  = flag.String("expect-regex", "", "Regular expression the -check-url response body must match")
+	minSize      = flag.Int64("min-size", 0, "Minimum -check-url response body size in bytes")
+	maxSize      = flag.Int64("max-size", 0, "Maximum -check-url response body size in bytes (0 = no limit)")
+	expectSHA256 = flag.String("expect-sha256", "", "SHA-256 of the -check-url response body, for a static resource")
+	targetsFile  = flag.String("targets", "", "YAML/JSON file of check targets with their expected responses, replacing -check-url and the -expect-* flags")
+	minPass      = flag.Int("min-pass", 0, "Checks out of -validations times the number of targets a proxy must pass (0 = all)")
+	eachTarget   = flag.Bool("each-target", false, "Pass proxies that succeed against each target in one of its -validations rounds, retrying failed targets instead of counting checks")
+
+	inputs        listFlag
+	headers       listFlag
+	expectHeaders listFlag
+	pinCerts      listFlag
 )
 
+func init() {
+	flag.Var(&inputs, "input", "Re-check proxies from a file instead of fetching (repeatable, - for stdin)")
+	flag.Var(&headers, "header", `Header sent to proxy sources as "Name: value" (repeatable)`)
+	flag.Var(&expectHeaders, "expect-header", `Header the -check-url response must have, as "Name" or "Name: text in value" (repeatable)`)
+	flag.Var(&pinCerts, "pin-cert", "SHA-256 fingerprint the -check-url TLS certificate must match, to detect interception (repeatable)")
+}
+
+// checkOptions configures the worker pool started by startChecking.
+type checkOptions struct {
+	threads     int
+	validations int
+	timeout     time.Duration
+	checkURL    string
+	debug       bool
+
+	// Try local and remote DNS variants of SOCKS proxies
+	detectVariants bool
+
+	// HTTP capability probing, nil when disabled, and the capabilities
+	// HTTP proxies must have
+	probes       *checker.Probes
+	capabilities []models.Capability
+
+	// Validation of the -check-url response beyond status 200
+	expect *checker.Expect
+
+	// Targets replacing checkURL when set, and how many of the checks
+	// against them must pass: 0 for all, or checker.EveryTarget for a pass
+	// on each target
+	targets []checker.Target
+	minPass int
+
+	// Anonymity detection, enabled when judgeURL is set
+	judgeURL  string
+	realIP    string
+	anonymity map[models.Anonymity]bool
+
+	// Optional persistent history of sightings and checks
+	db *store.Store
+
+	// Progress saved for -resume, nil when disabled
+	checkpoint *checkpoint
+}
+
+// checkTargets returns the targets proxies are validated against.
+func (o checkOptions) checkTargets() []checker.Target {
+	if len(o.targets) > 0 {
+		return o.targets
+	}
+	t := checker.Target{Name: o.checkURL, URL: o.checkURL}
+	if o.expect != nil {
+		t.Expect = *o.expect
+	}
+	return []checker.Target{t}
+}
+
+// keep reports whether a working proxy passes the anonymity and capability
+// filters.
+func (o checkOptions) keep(p models.Proxy) bool {
+	if p.Protocol == models.HTTP && !p.Can(o.capabilities...) {
+		return false
+	}
+	return len(o.anonymity) == 0 || o.anonymity[p.Anonymity]
+}
+
+// recordSeen stores fetched proxies in the database, if one is open.
+func (o checkOptions) recordSeen(proxies []models.Proxy) error {
+	if o.db == nil {
+		return nil
+	}
+	return o.db.AddSeen(proxies, time.Now())
+}
+
+// recordCheck stores the outcome of checking p in the database, if one is
+// open. failed is the zero Result when p passed.
+func (o checkOptions) recordCheck(p models.Proxy, failed checker.Result) error {
+	if o.db == nil {
+		return nil
+	}
+	c := store.Check{Time: time.Now(), OK: failed.Err == nil, Latency: p.Latency}
+	if !c.OK {
+		c.Latency = failed.Latency
+		c.Failure = string(failed.Failure)
+	}
+	return o.db.AddCheck(p, c)
+}
+
 type appState int
 
 const (
-	stateFetching appState = iota
-	stateChecking
+	stateFetching appState = iota // fetching, with checks running alongside
+	stateChecking                 // fetching finished, checks still running
 	stateDone
 )
 
 // Messages
 type logMsg string
-type newProxiesMsg []models.Proxy
+type newProxiesMsg struct {
+	fetched int // proxies delivered by the fetcher
+	unique  int // of those, how many were new and queued for checking
+}
 type validProxyMsg models.Proxy
+type checkProgressMsg checker.FailureKind
 type finishedFetchingMsg struct{}
 type finishedCheckingMsg struct{}
 
 // Channels for coordination
 var checkResults = make(chan models.Proxy, 100)
-var checkProgress = make(chan bool, 1000)
+var checkProgress = make(chan checker.FailureKind, 1000)
 var checkDone = make(chan bool)
 
 // Model
@@ -60,18 +198,25 @@ type model struct {
 	spinner  spinner.Model
 	progress progress.Model
 
-	allProxies   []models.Proxy
+	fetchedCount int
 	checkedCount int32
 	validCount   int32
 	totalToTest  int
 
-	outputFile *os.File
+	totalLatency time.Duration
+	failures     map[checker.FailureKind]int
+
+	outputFile output.Writer
+
+	// cancel stops fetchers and checkers on quit or once the requested
+	// number of valid proxies has been found.
+	cancel context.CancelFunc
 
 	width  int
 	height int
 }
 
-func initialModel() model {
+func initialModel(cancel context.CancelFunc, out output.Writer) model {
 	s := spinner.New()
 	s.Spinner = spinner.Dot
 	s.Style = lipgloss.NewStyle().Foreground(lipgloss.Color("205"))
@@ -81,20 +226,20 @@ func initialModel() model {
 
 	prog := progress.New(progress.WithDefaultGradient())
 
-	f, _ := os.Create(*outputFile)
-
 	return model{
 		state:      stateFetching,
 		spinner:    s,
 		viewport:   vp,
 		progress:   prog,
-		outputFile: f,
+		outputFile: out,
 		logs:       []string{},
+		failures:   make(map[checker.FailureKind]int),
+		cancel:     cancel,
 	}
 }
 
 func (m model) Init() tea.Cmd {
-	return m.spinner.Tick
+	return tea.Batch(m.spinner.Tick, waitForCheckEvent())
 }
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -105,9 +250,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch msg.String() {
 		case "q", "ctrl+c":
-			if m.outputFile != nil {
-				m.outputFile.Close()
-			}
+			m.cancel()
 			return m, tea.Quit
 		}
 
@@ -115,7 +258,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.width = msg.Width
 		m.height = msg.Height
 		m.viewport.Width = msg.Width
-		m.viewport.Height = msg.Height - 5
+		m.viewport.Height = max(msg.Height-9, 1)
 		m.progress.Width = msg.Width - 4
 
 	case logMsg:
@@ -128,36 +271,31 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case newProxiesMsg:
-		m.allProxies = append(m.allProxies, msg...)
+		m.fetchedCount += msg.fetched
+		m.totalToTest += msg.unique
+		return m, m.progress.SetPercent(m.percentChecked())
 
 	case finishedFetchingMsg:
 		m.state = stateChecking
-		m.deduplicate()
-
-		timeoutDuration := time.Duration(*timeout) * time.Second
-
-		// Start checking
-		startChecking(m.allProxies, *threads, *validations, timeoutDuration, *checkURL, *debug)
-		return m, waitForCheckEvent()
+		return m, nil
 
 	case validProxyMsg:
-		if m.outputFile != nil {
-			m.outputFile.WriteString(msg.IP + ":" + msg.Port + "\n")
-		}
+		writeProxy(m.outputFile, models.Proxy(msg))
 		atomic.AddInt32(&m.validCount, 1)
+		m.totalLatency += msg.Latency
 		limit := *proxyLimit
 		if limit > 0 && int(m.validCount) >= limit {
+			m.cancel()
 			return m, tea.Quit
 		}
 		return m, waitForCheckEvent()
 
-	case int: // Progress tick
+	case checkProgressMsg:
 		atomic.AddInt32(&m.checkedCount, 1)
-		pct := float64(m.checkedCount) / float64(m.totalToTest)
-		if m.totalToTest == 0 {
-			pct = 0
+		if kind := checker.FailureKind(msg); kind != checker.FailureNone {
+			m.failures[kind]++
 		}
-		cmd = m.progress.SetPercent(pct)
+		cmd = m.progress.SetPercent(m.percentChecked())
 		return m, tea.Batch(cmd, waitForCheckEvent())
 
 	case progress.FrameMsg:
@@ -181,46 +319,82 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, tea.Batch(cmds...)
 }
 
-func (m *model) deduplicate() {
-	unique := make(map[string]models.Proxy)
-	filterType := strings.ToLower(*proxyType)
-
-	for _, p := range m.allProxies {
-		if filterType != "" && string(p.Protocol) != filterType {
-			continue
-		}
-		key := fmt.Sprintf("%s:%s", p.IP, p.Port)
-		unique[key] = p
-	}
+// completed reports whether the run ended on its own rather than being
+// quit: all proxies were checked or the -proxy limit was reached.
+func (m model) completed() bool {
+	limit := *proxyLimit
+	return m.state == stateDone || (limit > 0 && int(atomic.LoadInt32(&m.validCount)) >= limit)
+}
 
-	m.allProxies = make([]models.Proxy, 0, len(unique))
-	for _, p := range unique {
-		m.allProxies = append(m.allProxies, p)
+func (m model) percentChecked() float64 {
+	if m.totalToTest == 0 {
+		return 0
 	}
-	m.totalToTest = len(m.allProxies)
+	return float64(atomic.LoadInt32(&m.checkedCount)) / float64(m.totalToTest)
 }
 
 func (m model) View() string {
+	if m.state == stateDone {
+		return "Done!"
+	}
+
+	valid := atomic.LoadInt32(&m.validCount)
+	checked := atomic.LoadInt32(&m.checkedCount)
+
+	status := fmt.Sprintf("Checking... Valid: %d | Checked: %d / %d", valid, checked, m.totalToTest)
+	if valid > 0 {
+		avg := m.totalLatency / time.Duration(valid)
+		status += fmt.Sprintf(" | Avg latency: %s", avg.Round(time.Millisecond))
+	}
+
 	if m.state == stateFetching {
-		header := fmt.Sprintf("%s Fetching proxies... Total fetched: %d", m.spinner.View(), len(m.allProxies))
-		return fmt.Sprintf("%s\n\n%s\n\nPres q to quit.", header, m.viewport.View())
+		header := fmt.Sprintf("%s Fetching proxies... Total fetched: %d", m.spinner.View(), m.fetchedCount)
+		return fmt.Sprintf("%s\n%s\n%s\n%s\n\n%s\n\nPress q to quit.", header, status, m.failureSummary(), m.progress.View(), m.viewport.View())
 	}
 
-	if m.state == stateChecking {
-		valid := atomic.LoadInt32(&m.validCount)
-		checked := atomic.LoadInt32(&m.checkedCount)
+	return fmt.Sprintf("\n%s\n%s\n%s\n\nPress q to quit.", status, m.failureSummary(), m.progress.View())
+}
 
-		status := fmt.Sprintf("Checking... Valid: %d | Checked: %d / %d", valid, checked, m.totalToTest)
-		return fmt.Sprintf("\n%s\n%s\n\nPress q to quit.", status, m.progress.View())
+// failureSummary lists failed checks by category in a stable order.
+func (m model) failureSummary() string {
+	kinds := []checker.FailureKind{
+		checker.FailureDial,
+		checker.FailureHandshake,
+		checker.FailureTLS,
+		checker.FailureTimeout,
+		checker.FailureStatus,
+		checker.FailureBody,
+	}
+	// Validation failures only occur with -expect-* flags
+	optional := []checker.FailureKind{
+		checker.FailureContent,
+		checker.FailureHeader,
+		checker.FailureSize,
+		checker.FailureHash,
+		checker.FailureCertificate,
 	}
 
-	return "Done!"
+	parts := make([]string, 0, len(kinds)+len(optional))
+	for _, kind := range kinds {
+		parts = append(parts, fmt.Sprintf("%s: %d", kind, m.failures[kind]))
+	}
+	for _, kind := range optional {
+		if n := m.failures[kind]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s: %d", kind, n))
+		}
+	}
+	return "Failed - " + strings.Join(parts, " | ")
 }
 
 // Helpers
 
-func startChecking(proxies []models.Proxy, threads int, validations int, timeout time.Duration, checkURL string, debugMode bool) {
-	jobs := make(chan models.Proxy, len(proxies))
+// startChecking starts the worker pool. Proxies are queued with Submit while
+// fetching is still running; checkDone is sent after Close once every queued
+// proxy has been checked, or after ctx is cancelled.
+func startChecking(ctx context.Context, opts checkOptions) *checkPool {
+	debugMode := opts.debug
+	pool := &checkPool{ctx: ctx, in: make(chan []models.Proxy, 16)}
+	jobs := make(chan models.Proxy)
 
 	// Debug file setup
 	var debugFile *os.File
@@ -259,113 +433,566 @@ func startChecking(proxies []models.Proxy, threads int, validations int, timeout
 		}
 	}
 
+	targets := opts.checkTargets()
+
 	var wg sync.WaitGroup
-	for i := 0; i < threads; i++ {
+	for i := 0; i < opts.threads; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
 			for p := range jobs {
-				isValid := true
-				var lastErr error
-				for v := 0; v < validations; v++ {
-					ok, err := checker.Check(p, checkURL, timeout)
-					if !ok {
-						isValid = false
-						lastErr = err
-						break
+				if ctx.Err() != nil {
+					continue
+				}
+
+				// A proxy listed as socks4 may only work as socks4a, and so on
+				if opts.detectVariants && p.Protocol.Base() != models.HTTP {
+					p.Variants = checker.DetectVariants(ctx, p, targets, opts.timeout)
+					if len(p.Variants) > 0 && !slices.Contains(p.Variants, p.Protocol) {
+						p.Protocol = p.Variants[0]
+					}
+				}
+
+				var failed checker.Result
+				res, results := checker.CheckTargets(ctx, p, targets, opts.validations, opts.minPass, opts.timeout)
+				p.Targets = results
+				if res.OK {
+					p.Latency = res.Latency
+					if res.ExitIP != "" {
+						p.ExitIP = res.ExitIP
+					}
+				} else {
+					failed = res
+				}
+
+				if failed.Err == nil && opts.judgeURL != "" {
+					level, res := checker.Judge(ctx, p, opts.judgeURL, opts.realIP, opts.timeout)
+					if !res.OK {
+						failed = res
+					}
+					p.Anonymity = level
+					if res.ExitIP != "" {
+						p.ExitIP = res.ExitIP
 					}
 				}
 
-				checkProgress <- true
-				if isValid {
+				if failed.Err == nil && opts.probes != nil && p.Protocol == models.HTTP {
+					p.Capabilities = checker.ProbeCapabilities(ctx, p, *opts.probes, opts.timeout)
+				}
+
+				// Checks cut short by cancellation say nothing about the proxy
+				if ctx.Err() == nil {
+					if err := opts.recordCheck(p, failed); err != nil {
+						debugLog(fmt.Sprintf("%s:%s (%s) -> Database error: %v", p.IP, p.Port, p.Protocol, err))
+					}
+					opts.checkpoint.addChecked(p, failed.Err == nil && opts.keep(p))
+				}
+
+				checkProgress <- failed.Failure
+				if failed.Err == nil {
+					if !opts.keep(p) {
+						if debugMode {
+							debugLog(fmt.Sprintf("%s:%s (%s) -> Skipped: anonymity %s, capabilities %v", p.IP, p.Port, p.Protocol, p.Anonymity, p.Capabilities))
+						}
+						continue
+					}
 					checkResults <- p
 				} else {
 					// Log failure
-					if debugMode && lastErr != nil {
-						debugLog(fmt.Sprintf("%s:%s (%s) -> Failed: %v", p.IP, p.Port, p.Protocol, lastErr))
+					if debugMode {
+						debugLog(fmt.Sprintf("%s:%s (%s) -> Failed [%s] after %s: %v", p.IP, p.Port, p.Protocol, failed.Failure, failed.Latency.Round(time.Millisecond), failed.Err))
 					}
 				}
 			}
 		}()
 	}
 
+	go pool.feed(jobs)
+
 	go func() {
-		for _, p := range proxies {
-			jobs <- p
-		}
-		close(jobs)
 		wg.Wait()
 		if debugFile != nil {
 			debugFile.Close()
 		}
 		checkDone <- true
 	}()
+
+	return pool
+}
+
+// setupAnonymity parses the -anonymity filter and, when a judge is
+// configured, looks up our own IP so transparent proxies can be spotted.
+func (o *checkOptions) setupAnonymity(levels string) error {
+	if levels != "" {
+		if o.judgeURL == "" {
+			return errors.New("-anonymity requires -judge-url")
+		}
+		o.anonymity = make(map[models.Anonymity]bool)
+		for _, name := range strings.Split(levels, ",") {
+			level, err := models.ParseAnonymity(name)
+			if err != nil {
+				return err
+			}
+			o.anonymity[level] = true
+		}
+	}
+
+	if o.judgeURL == "" {
+		return nil
+	}
+
+	ip, err := checker.RealIP(context.Background(), o.judgeURL, o.timeout)
+	if err != nil {
+		return fmt.Errorf("querying judge %s: %w", o.judgeURL, err)
+	}
+	o.realIP = ip
+	return nil
+}
+
+// setupProbes enables HTTP capability probing and parses the -capabilities
+// filter.
+func (o *checkOptions) setupProbes(probe bool, required string) error {
+	if required != "" {
+		if !probe {
+			return errors.New("-capabilities requires -probe-http")
+		}
+		caps, err := models.ParseCapabilities(required)
+		if err != nil {
+			return err
+		}
+		o.capabilities = caps
+	}
+
+	if !probe {
+		return nil
+	}
+	probes, err := checker.DefaultProbes(o.checkURL)
+	if err != nil {
+		return err
+	}
+	o.probes = &probes
+	return nil
+}
+
+// setupExpect builds the response validation from the -expect-*, -min-size,
+// -max-size and -pin-cert flags.
+func (o *checkOptions) setupExpect() error {
+	e := checker.Expect{
+		Contains: *expectBody,
+		MinSize:  *minSize,
+		MaxSize:  *maxSize,
+	}
+	if e.MinSize < 0 || e.MaxSize < 0 || (e.MaxSize > 0 && e.MinSize > e.MaxSize) {
+		return errors.New("-min-size and -max-size must not be negative, and -min-size not above -max-size")
+	}
+	if *expectRegex != "" {
+		re, err := regexp.Compile(*expectRegex)
+		if err != nil {
+			return fmt.Errorf("invalid -expect-regex: %w", err)
+		}
+		e.Pattern = re
+	}
+	for _, h := range expectHeaders {
+		name, value, _ := strings.Cut(h, ":")
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("invalid -expect-header %q, want \"Name\" or \"Name: value\"", h)
+		}
+		if e.Header == nil {
+			e.Header = make(map[string]string)
+		}
+		e.Header[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	if *expectSHA256 != "" {
+		sum, err := checker.ParseFingerprint(*expectSHA256)
+		if err != nil {
+			return fmt.Errorf("invalid -expect-sha256: %w", err)
+		}
+		e.SHA256 = sum
+	}
+	for _, pin := range pinCerts {
+		fp, err := checker.ParseFingerprint(pin)
+		if err != nil {
+			return fmt.Errorf("invalid -pin-cert: %w", err)
+		}
+		e.CertSHA256 = append(e.CertSHA256, fp)
+	}
+
+	o.expect = &e
+	return nil
+}
+
+// setupTargets loads the -targets file and applies the -min-pass or
+// -each-target policy.
+func (o *checkOptions) setupTargets(path string, minPass int, eachTarget bool) error {
+	if minPass < 0 {
+		return errors.New("-min-pass must not be negative")
+	}
+	if eachTarget && minPass > 0 {
+		return errors.New("-min-pass and -each-target are exclusive")
+	}
+	o.minPass = minPass
+	if eachTarget {
+		o.minPass = checker.EveryTarget
+	}
+	if path == "" {
+		return nil
+	}
+	targets, err := checker.LoadTargets(path)
+	if err != nil {
+		return fmt.Errorf("loading targets: %w", err)
+	}
+	o.targets = targets
+	return nil
+}
+
+// runJudge implements the judge command, which serves the built-in proxy
+// judge so -judge-url can point at a machine we control.
+func runJudge(args []string) {
+	fs := flag.NewFlagSet("judge", flag.ExitOnError)
+	listen := fs.String("listen", ":8090", "Address to listen on")
+	fs.Parse(args)
+
+	fmt.Printf("Proxy judge listening on %s\n", *listen)
+	if err := judge.ListenAndServe(*listen); err != nil {
+		fmt.Printf("Error running judge: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// runFetchers runs every fetcher concurrently and returns once all are done.
+func runFetchers(ctx context.Context, fetchers []fetcher.Fetcher, logger fetcher.Logger, onProxies fetcher.ProxyCallback) {
+	var wg sync.WaitGroup
+	for _, f := range fetchers {
+		wg.Add(1)
+		go func(f fetcher.Fetcher) {
+			defer wg.Done()
+			if err := f.Fetch(ctx, logger, onProxies); err != nil && !errors.Is(err, context.Canceled) {
+				logger(fmt.Sprintf("Error: %v", err))
+			}
+		}(f)
+	}
+	wg.Wait()
+}
+
+// openOutput creates the output file and a writer for the chosen format.
+func openOutput(path, format string) (output.Writer, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := output.New(format, f)
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	return w, nil
+}
+
+// writeProxy appends a valid proxy to the output file.
+func writeProxy(w output.Writer, p models.Proxy) {
+	if w != nil {
+		w.Write(p)
+	}
 }
 
 func waitForCheckEvent() tea.Cmd {
 	return func() tea.Msg {
+		// checkDone is only sent once every worker has finished, so buffered
+		// results must be drained before it is considered.
 		select {
 		case p := <-checkResults:
 			return validProxyMsg(p)
-		case <-checkProgress:
-			return int(1) // progress tick
+		case kind := <-checkProgress:
+			return checkProgressMsg(kind)
+		default:
+		}
+
+		select {
+		case p := <-checkResults:
+			return validProxyMsg(p)
+		case kind := <-checkProgress:
+			return checkProgressMsg(kind)
 		case <-checkDone:
 			return finishedCheckingMsg{}
 		}
 	}
 }
 
+// fetchClient builds the HTTP client used by every source from the fetch
+// flags.
+func fetchClient() (*http.Client, error) {
+	if *fetchRate < 0 || *fetchConns < 0 {
+		return nil, errors.New("-fetch-rate and -fetch-concurrency must not be negative")
+	}
+	retries := *fetchRetry
+	if retries == 0 {
+		retries = -1
+	}
+
+	header := make(http.Header)
+	for _, h := range headers {
+		name, value, ok := strings.Cut(h, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid -header %q, want \"Name: value\"", h)
+		}
+		header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	var cache *fetcher.Cache
+	if *offline && *cacheDir == "" {
+		return nil, errors.New("-offline requires -cache")
+	}
+	if *cacheDir != "" {
+		var err error
+		if cache, err = fetcher.NewCache(*cacheDir, *offline); err != nil {
+			return nil, fmt.Errorf("opening cache: %w", err)
+		}
+		cache.Refresh = *cacheRefresh
+	}
+
+	return fetcher.NewClient(fetcher.ClientOptions{
+		Retries:         retries,
+		HostRate:        *fetchRate,
+		HostConcurrency: *fetchConns,
+		UserAgent:       *userAgent,
+		Header:          header,
+		Cache:           cache,
+	}), nil
+}
+
+// loadFetchPool builds the pool "pool" sources are fetched through: the n
+// best-scored working proxies in db, or a random n from a proxy list file.
+func loadFetchPool(from string, n int, db *store.Store) (*gateway.Pool, error) {
+	var proxies []models.Proxy
+	if from == "db" {
+		if db == nil {
+			return nil, errors.New(`"db" requires -db`)
+		}
+		records, err := db.All()
+		if err != nil {
+			return nil, err
+		}
+		var working []store.Record
+		for _, r := range records {
+			if c, ok := r.LastCheck(); ok && c.OK {
+				working = append(working, r)
+			}
+		}
+		for _, r := range store.Top(working, n, time.Now()) {
+			proxies = append(proxies, r.Proxy)
+		}
+	} else {
+		file, err := os.Open(from)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		list, _, err := readProxyList(file, models.HTTP, from)
+		if err != nil {
+			return nil, err
+		}
+		rand.Shuffle(len(list), func(i, j int) { list[i], list[j] = list[j], list[i] })
+		proxies = list[:min(n, len(list))]
+	}
+
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("no proxies in %s", from)
+	}
+	return gateway.NewPool(proxies, gateway.RoundRobin, 2), nil
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "judge":
+			runJudge(os.Args[2:])
+			return
+		case "serve":
+			runServe(os.Args[2:])
+			return
+		case "db":
+			runDB(os.Args[2:])
+			return
+		case "record-fixtures":
+			runRecordFixtures(os.Args[2:])
+			return
+		}
+	}
+
 	flag.Parse()
 
-	m := initialModel()
+	opts := checkOptions{
+		threads:     *threads,
+		validations: *validations,
+		timeout:     time.Duration(*timeout) * time.Second,
+		checkURL:    *checkURL,
+		debug:       *debug,
+		judgeURL:    *judgeURL,
+
+		detectVariants: *detectSOCKS,
+	}
+	if err := opts.setupAnonymity(*anonymity); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := opts.setupProbes(*probeHTTP, *capabilities); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := opts.setupExpect(); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := opts.setupTargets(*targetsFile, *minPass, *eachTarget); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	var fetchers []fetcher.Fetcher
+	usesStdin := false
+	if len(inputs) > 0 {
+		var err error
+		fetchers, usesStdin, err = inputFetchers(inputs, *inputProto)
+		if err == nil {
+			err = checkNotOutput(inputs, *outputFile)
+		}
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		client, err := fetchClient()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fetcher.DefaultClient = client
+
+		sources, err := fetcher.LoadSources(*sourcesFile)
+		if err != nil {
+			fmt.Printf("Error loading sources: %v\n", err)
+			os.Exit(1)
+		}
+		for i := range sources {
+			if sources[i].Proxy == "" {
+				sources[i].Proxy = *fetchProxy
+			}
+			if sources[i].IsEnabled() && sources[i].Proxy == fetcher.RoutePool && *fetchPool == "" {
+				fmt.Printf("Error: source %q is fetched through the proxy pool, which requires -fetch-pool\n", sources[i].Name)
+				os.Exit(1)
+			}
+		}
+		fetchers, err = fetcher.BuildFetchers(sources)
+		if err != nil {
+			fmt.Printf("Invalid sources:\n%v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *dbPath != "" {
+		db, err := store.Open(*dbPath)
+		if err != nil {
+			fmt.Printf("Error opening database %s: %v\n", *dbPath, err)
+			os.Exit(1)
+		}
+		defer db.Close()
+		opts.db = db
+	}
+
+	if *fetchPool != "" && len(inputs) == 0 {
+		pool, err := loadFetchPool(*fetchPool, *poolSize, opts.db)
+		if err != nil {
+			fmt.Printf("Error loading -fetch-pool: %v\n", err)
+			os.Exit(1)
+		}
+		fetcher.ProxyPool = pool
+	}
+
+	if *resume && *statePath == "" {
+		fmt.Println("Error: -resume requires -state")
+		os.Exit(1)
+	}
+	if *statePath != "" {
+		cp, err := newCheckpoint(*statePath, *resume)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts.checkpoint = cp
+	}
+
+	out, err := openOutput(*outputFile, *format)
+	if err != nil {
+		fmt.Printf("Error opening output: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *headless || usesStdin || !isatty.IsTerminal(os.Stdout.Fd()) {
+		code := runHeadless(opts, fetchers, out)
+		out.Close()
+		if opts.db != nil {
+			opts.db.Close()
+		}
+		os.Exit(code)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dedup := newDeduper(*proxyType)
+	restored, remaining := opts.checkpoint.restore(dedup, out)
+
+	m := initialModel(cancel, out)
+	m.validCount = int32(len(restored))
+	for _, p := range restored {
+		m.totalLatency += p.Latency
+	}
 	p := tea.NewProgram(m)
 
+	pool := startChecking(ctx, opts)
+	opts.checkpoint.start(checkpointInterval)
+
 	go func() {
 		logger := func(msg string) {
 			p.Send(logMsg(msg))
 		}
 
+		if *resume {
+			logger(fmt.Sprintf("Resuming: %d valid proxies restored, %d left to check", len(restored), len(remaining)))
+			p.Send(newProxiesMsg{fetched: len(remaining), unique: len(remaining)})
+			pool.Submit(remaining)
+		}
+
 		onProxies := func(proxies []models.Proxy) {
-			p.Send(newProxiesMsg(proxies))
-		}
-
-		fetchers := []fetcher.Fetcher{
-			&fetcher.TextFetcher{URL: "https://raw.githubusercontent.com/iplocate/free-proxy-list/refs/heads/main/all-proxies.txt", Protocol: models.HTTP, Source: "iplocate"},
-			&fetcher.TextFetcher{URL: "https://api.proxyscrape.com/v4/free-proxy-list/get?request=get_proxies&skip=0&proxy_format=protocolipport&format=text&limit=1000000&timeout=200000", Protocol: models.HTTP, Source: "proxyscrape"},
-			&fetcher.TextFetcher{URL: "https://raw.githubusercontent.com/TheSpeedX/SOCKS-List/master/socks5.txt", Protocol: models.SOCKS5, Source: "TheSpeedX-SOCKS5"},
-			&fetcher.TextFetcher{URL: "https://raw.githubusercontent.com/TheSpeedX/SOCKS-List/master/socks4.txt", Protocol: models.SOCKS4, Source: "TheSpeedX-SOCKS4"},
-			&fetcher.TextFetcher{URL: "https://raw.githubusercontent.com/TheSpeedX/SOCKS-List/master/http.txt", Protocol: models.HTTP, Source: "TheSpeedX-HTTP"},
-			&fetcher.GeonodeFetcher{
-				BaseURL: "https://proxylist.geonode.com/api/proxy-list?limit=500&sort_by=lastChecked&sort_type=desc",
-				Limit:   500,
-				Pages:   10,
-			},
-			&fetcher.HTMLFetcher{
-				URL:    "https://free-proxy-list.net/ru/",
-				Source: "free-proxy-list.net",
-			},
-			&fetcher.ProxyDBFetcher{
-				BaseURL: "https://proxydb.net/",
-				Source:  "proxydb.net",
-			},
-		}
-
-		var wg sync.WaitGroup
-		for _, f := range fetchers {
-			wg.Add(1)
-			go func(f fetcher.Fetcher) {
-				defer wg.Done()
-				if err := f.Fetch(logger, onProxies); err != nil {
-					p.Send(logMsg(fmt.Sprintf("Error: %v", err)))
-				}
-			}(f)
+			if err := opts.recordSeen(proxies); err != nil {
+				logger(fmt.Sprintf("Database error: %v", err))
+			}
+			fresh := dedup.Add(proxies)
+			opts.checkpoint.addFetched(fresh)
+			p.Send(newProxiesMsg{fetched: len(proxies), unique: len(fresh)})
+			pool.Submit(fresh)
 		}
-		wg.Wait()
+
+		if opts.checkpoint.fetchDone() {
+			logger("Fetching finished before the interruption, skipping sources")
+		} else {
+			runFetchers(ctx, fetchers, logger, onProxies)
+			if ctx.Err() == nil {
+				opts.checkpoint.setFetchDone()
+			}
+		}
+		pool.Close()
 		p.Send(finishedFetchingMsg{})
 	}()
 
-	if _, err := p.Run(); err != nil {
+	final, err := p.Run()
+	out.Close()
+	fm, _ := final.(model)
+	if cpErr := opts.checkpoint.finish(fm.completed()); cpErr != nil {
+		fmt.Printf("Error saving %s: %v\n", *statePath, cpErr)
+	}
+	if err != nil {
 		fmt.Printf("Error running program: %v\n", err)
 		os.Exit(1)
 	}
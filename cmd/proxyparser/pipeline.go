@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"ProxyParserGO/pkg/models"
+)
+
+// deduper lets each address through once per run, optionally restricted to
+// a single protocol. It is safe for concurrent use by fetchers.
+type deduper struct {
+	mu         sync.Mutex
+	seen       map[string]bool
+	filterType models.Protocol
+}
+
+// newDeduper restricts proxies to filterType when it is set. Sources list
+// SOCKS proxies by their base protocol, so socks4a and socks5h select socks4
+// and socks5 proxies, which are then checked with remote DNS.
+func newDeduper(filterType string) *deduper {
+	d := &deduper{seen: make(map[string]bool)}
+	if filterType != "" {
+		d.filterType = models.Protocol(strings.ToLower(filterType))
+		if p, err := models.ParseProtocol(filterType); err == nil {
+			d.filterType = p
+		}
+	}
+	return d
+}
+
+// Add returns the proxies from batch that have not been seen before.
+func (d *deduper) Add(batch []models.Proxy) []models.Proxy {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var fresh []models.Proxy
+	for _, p := range batch {
+		if d.filterType != "" {
+			if p.Protocol.Base() != d.filterType.Base() {
+				continue
+			}
+			if d.filterType.RemoteDNS() {
+				p.Protocol = d.filterType
+			}
+		}
+		key := p.Address()
+		if d.seen[key] {
+			continue
+		}
+		d.seen[key] = true
+		fresh = append(fresh, p)
+	}
+	return fresh
+}
+
+// checkPool queues proxies for the workers started by startChecking. The
+// queue is unbounded so fetchers never wait on slow checks.
+type checkPool struct {
+	ctx       context.Context
+	in        chan []models.Proxy
+	closeOnce sync.Once
+}
+
+// Submit queues proxies for checking. It must not be called after Close.
+func (c *checkPool) Submit(proxies []models.Proxy) {
+	if len(proxies) == 0 {
+		return
+	}
+	select {
+	case c.in <- proxies:
+	case <-c.ctx.Done():
+	}
+}
+
+// Close signals that no more proxies will be submitted.
+func (c *checkPool) Close() {
+	c.closeOnce.Do(func() { close(c.in) })
+}
+
+// feed moves queued proxies to the workers until the pool is closed and
+// drained, or the context is cancelled.
+func (c *checkPool) feed(jobs chan<- models.Proxy) {
+	defer close(jobs)
+
+	var pending []models.Proxy
+	in := c.in
+	for in != nil || len(pending) > 0 {
+		var out chan<- models.Proxy
+		var next models.Proxy
+		if len(pending) > 0 {
+			out = jobs
+			next = pending[0]
+		}
+
+		select {
+		case <-c.ctx.Done():
+			return
+		case batch, ok := <-in:
+			if !ok {
+				in = nil
+				continue
+			}
+			pending = append(pending, batch...)
+		case out <- next:
+			pending = pending[1:]
+		}
+	}
+}
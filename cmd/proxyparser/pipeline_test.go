@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	"ProxyParserGO/pkg/checker"
+	"ProxyParserGO/pkg/models"
+	"ProxyParserGO/pkg/proxytest"
+)
+
+func TestDeduper(t *testing.T) {
+	d := newDeduper("socks5")
+
+	first := d.Add([]models.Proxy{
+		{IP: "10.0.0.1", Port: "1080", Protocol: models.SOCKS5},
+		{IP: "10.0.0.2", Port: "8080", Protocol: models.HTTP},
+		{IP: "10.0.0.1", Port: "1080", Protocol: models.SOCKS5},
+	})
+	if len(first) != 1 || first[0].IP != "10.0.0.1" {
+		t.Fatalf("first batch = %v, want only 10.0.0.1", first)
+	}
+
+	second := d.Add([]models.Proxy{
+		{IP: "10.0.0.1", Port: "1080", Protocol: models.SOCKS5},
+		{IP: "10.0.0.3", Port: "1080", Protocol: models.SOCKS5},
+	})
+	if len(second) != 1 || second[0].IP != "10.0.0.3" {
+		t.Fatalf("second batch = %v, want only 10.0.0.3", second)
+	}
+}
+
+func TestDeduperRemoteDNS(t *testing.T) {
+	d := newDeduper("socks5h")
+	got := d.Add([]models.Proxy{
+		{IP: "10.0.0.1", Port: "1080", Protocol: models.SOCKS5},
+		{IP: "10.0.0.2", Port: "1080", Protocol: models.SOCKS4},
+		{IP: "10.0.0.3", Port: "1080", Protocol: models.SOCKS5H},
+	})
+	// socks5 proxies are let through to be checked with remote DNS
+	if len(got) != 2 || got[0].Protocol != models.SOCKS5H || got[1].Protocol != models.SOCKS5H {
+		t.Errorf("got %v, want 10.0.0.1 and 10.0.0.3 as socks5h", got)
+	}
+}
+
+// runPool checks proxies, submitted in batches, and collects the results.
+func runPool(t *testing.T, ctx context.Context, opts checkOptions, batches ...[]models.Proxy) ([]models.Proxy, map[checker.FailureKind]int) {
+	t.Helper()
+
+	pool := startChecking(ctx, opts)
+	go func() {
+		for _, batch := range batches {
+			pool.Submit(batch)
+		}
+		pool.Close()
+	}()
+
+	var valid []models.Proxy
+	failures := make(map[checker.FailureKind]int)
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case p := <-checkResults:
+			valid = append(valid, p)
+		case kind := <-checkProgress:
+			failures[kind]++
+		case <-checkDone:
+			// Drain anything still buffered
+			for {
+				select {
+				case p := <-checkResults:
+					valid = append(valid, p)
+				case kind := <-checkProgress:
+					failures[kind]++
+				default:
+					return valid, failures
+				}
+			}
+		case <-timeout:
+			t.Fatal("worker pool did not finish")
+		}
+	}
+}
+
+func TestWorkerPool(t *testing.T) {
+	target := proxytest.NewTarget(http.StatusOK, "203.0.113.7")
+	defer target.Close()
+
+	var servers []*proxytest.Server
+	start := func(newProxy func(proxytest.Options) (*proxytest.Server, error), mode proxytest.Mode) models.Proxy {
+		s, err := newProxy(proxytest.Options{Mode: mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+		servers = append(servers, s)
+		return s.Proxy()
+	}
+	defer func() {
+		for _, s := range servers {
+			s.Close()
+		}
+	}()
+
+	good := []models.Proxy{
+		start(proxytest.NewHTTPProxy, proxytest.ModeOK),
+		start(proxytest.NewSOCKS4Proxy, proxytest.ModeOK),
+		start(proxytest.NewSOCKS5Proxy, proxytest.ModeOK),
+	}
+	bad := []models.Proxy{
+		start(proxytest.NewHTTPProxy, proxytest.ModeRefuse),
+		start(proxytest.NewSOCKS4Proxy, proxytest.ModeBadReply),
+		start(proxytest.NewSOCKS5Proxy, proxytest.ModeHang),
+	}
+
+	opts := checkOptions{
+		threads:     3,
+		validations: 2,
+		timeout:     500 * time.Millisecond,
+		checkURL:    target.URL,
+	}
+	valid, failures := runPool(t, context.Background(), opts, good[:2], bad, good[2:])
+
+	var got, want []string
+	for _, p := range valid {
+		got = append(got, p.String())
+		if p.Latency <= 0 || p.ExitIP != "203.0.113.7" {
+			t.Errorf("%s: latency=%s exitIP=%q", p, p.Latency, p.ExitIP)
+		}
+	}
+	for _, p := range good {
+		want = append(want, p.String())
+	}
+	sort.Strings(got)
+	sort.Strings(want)
+	if len(got) != len(want) {
+		t.Fatalf("valid = %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("valid = %v, want %v", got, want)
+		}
+	}
+
+	if failures[checker.FailureNone] != 3 || failures[checker.FailureDial] != 1 ||
+		failures[checker.FailureHandshake] != 1 || failures[checker.FailureTimeout] != 1 {
+		t.Errorf("unexpected progress counts: %v", failures)
+	}
+}
+
+func TestWorkerPoolCancel(t *testing.T) {
+	s, err := proxytest.NewSOCKS5Proxy(proxytest.Options{Mode: proxytest.ModeHang})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	proxies := make([]models.Proxy, 50)
+	for i := range proxies {
+		proxies[i] = s.Proxy()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+
+	opts := checkOptions{threads: 2, validations: 1, timeout: 5 * time.Second, checkURL: "http://example.test/"}
+	start := time.Now()
+	runPool(t, ctx, opts, proxies)
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Fatalf("pool took %s to stop after cancel", elapsed)
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"ProxyParserGO/pkg/gateway"
+	"ProxyParserGO/pkg/models"
+)
+
+// runServe implements the serve command: a local HTTP and SOCKS5 endpoint
+// that rotates client connections across previously validated proxies.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	file := fs.String("file", "valid_proxies.txt", "File with validated proxies")
+	protocol := fs.String("protocol", "http", "Protocol for lines without a scheme: http, socks4, socks5, socks4a, socks5h")
+	httpAddr := fs.String("http", "127.0.0.1:8080", "HTTP proxy listen address (empty to disable)")
+	socksAddr := fs.String("socks", "127.0.0.1:1080", "SOCKS5 listen address (empty to disable)")
+	strategyName := fs.String("strategy", "round-robin", "Upstream selection: round-robin, random, least-latency")
+	maxFailures := fs.Int("max-failures", 3, "Consecutive failures before an upstream is evicted")
+	retries := fs.Int("retries", 3, "Upstreams to try per client connection")
+	timeoutSec := fs.Int("timeout", 10, "Timeout in seconds for upstream connections")
+	fs.Parse(args)
+
+	logger := log.New(os.Stdout, "", log.LstdFlags)
+	fail := func(format string, args ...any) {
+		logger.Printf(format, args...)
+		os.Exit(1)
+	}
+
+	defaultProtocol, err := models.ParseProtocol(*protocol)
+	if err != nil {
+		fail("Error: %v", err)
+	}
+	strategy, err := gateway.ParseStrategy(*strategyName)
+	if err != nil {
+		fail("Error: %v", err)
+	}
+	if *httpAddr == "" && *socksAddr == "" {
+		fail("Error: at least one of -http and -socks must be set")
+	}
+
+	f, err := os.Open(*file)
+	if err != nil {
+		fail("Error opening proxy list: %v", err)
+	}
+	proxies, skipped, err := readProxyList(f, defaultProtocol, *file)
+	f.Close()
+	if err != nil {
+		fail("Error reading proxy list: %v", err)
+	}
+	if len(proxies) == 0 {
+		fail("No proxies found in %s", *file)
+	}
+	logger.Printf("Loaded %d upstream proxies from %s (%d lines skipped)", len(proxies), *file, skipped)
+
+	pool := gateway.NewPool(proxies, strategy, *maxFailures)
+	pool.OnEvict = func(p models.Proxy, err error) {
+		logger.Printf("Evicted %s: %v (%d left)", p, err, pool.Len())
+	}
+
+	server := &gateway.Server{
+		Pool:    pool,
+		Timeout: time.Duration(*timeoutSec) * time.Second,
+		Retries: *retries,
+		Logger:  func(msg string) { logger.Print(msg) },
+	}
+
+	errs := make(chan error, 2)
+	if *httpAddr != "" {
+		go func() {
+			logger.Printf("HTTP proxy listening on %s", *httpAddr)
+			errs <- http.ListenAndServe(*httpAddr, server)
+		}()
+	}
+	if *socksAddr != "" {
+		l, err := net.Listen("tcp", *socksAddr)
+		if err != nil {
+			fail("Error listening on %s: %v", *socksAddr, err)
+		}
+		go func() {
+			logger.Printf("SOCKS5 proxy listening on %s", *socksAddr)
+			errs <- server.ServeSOCKS5(l)
+		}()
+	}
+
+	if err := <-errs; err != nil {
+		fail("Error: %v", err)
+	}
+	fmt.Println("Gateway stopped")
+}
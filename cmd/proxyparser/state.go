@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"ProxyParserGO/internal/fileutil"
+	"ProxyParserGO/pkg/models"
+	"ProxyParserGO/pkg/output"
+)
+
+// checkpointInterval is how often run progress is saved to the state file.
+const checkpointInterval = 10 * time.Second
+
+// stateVersion is bumped when runState changes incompatibly.
+const stateVersion = 1
+
+// runState is the on-disk progress of a run, enough to resume it.
+type runState struct {
+	Version   int            `json:"version"`
+	Saved     time.Time      `json:"saved"`
+	FetchDone bool           `json:"fetch_done"`
+	Fetched   []models.Proxy `json:"fetched"`
+	Checked   []string       `json:"checked"`
+	Valid     []models.Proxy `json:"valid"`
+}
+
+// checkpoint tracks which proxies have been fetched, checked and found
+// valid, and periodically saves that to a state file. A nil checkpoint
+// ignores every call, so callers need not check whether it is enabled.
+type checkpoint struct {
+	path string
+
+	mu      sync.Mutex
+	state   runState
+	checked map[string]bool
+	dirty   bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// newCheckpoint starts tracking a run, saving to path. When resume is set
+// the previous state is loaded from path, which must exist.
+func newCheckpoint(path string, resume bool) (*checkpoint, error) {
+	c := &checkpoint{
+		path:    path,
+		state:   runState{Version: stateVersion},
+		checked: make(map[string]bool),
+	}
+	if !resume {
+		return c, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("no interrupted run to resume: %s does not exist", path)
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &c.state); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if c.state.Version != stateVersion {
+		return nil, fmt.Errorf("%s: unsupported state version %d", path, c.state.Version)
+	}
+	for _, key := range c.state.Checked {
+		c.checked[key] = true
+	}
+	return c, nil
+}
+
+// restore replays a resumed run: every proxy fetched before is marked as
+// seen by dedup, the valid ones are written to out again and returned, and
+// the ones that were not checked yet are returned for queueing.
+func (c *checkpoint) restore(dedup *deduper, out output.Writer) (valid, remaining []models.Proxy) {
+	if c == nil {
+		return nil, nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, p := range c.state.Valid {
+		writeProxy(out, p)
+	}
+	for _, p := range dedup.Add(c.state.Fetched) {
+		if !c.checked[p.Address()] {
+			remaining = append(remaining, p)
+		}
+	}
+	return append([]models.Proxy(nil), c.state.Valid...), remaining
+}
+
+// fetchDone reports whether the resumed run had finished fetching.
+func (c *checkpoint) fetchDone() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state.FetchDone
+}
+
+// setFetchDone records that every source has been fetched.
+func (c *checkpoint) setFetchDone() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.state.FetchDone = true
+	c.dirty = true
+	c.mu.Unlock()
+}
+
+// addFetched records newly fetched, deduplicated proxies.
+func (c *checkpoint) addFetched(proxies []models.Proxy) {
+	if c == nil || len(proxies) == 0 {
+		return
+	}
+	c.mu.Lock()
+	c.state.Fetched = append(c.state.Fetched, proxies...)
+	c.dirty = true
+	c.mu.Unlock()
+}
+
+// addChecked records that p has been checked and whether it was kept.
+func (c *checkpoint) addChecked(p models.Proxy, valid bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	key := p.Address()
+	if !c.checked[key] {
+		c.checked[key] = true
+		c.state.Checked = append(c.state.Checked, key)
+	}
+	if valid {
+		c.state.Valid = append(c.state.Valid, p)
+	}
+	c.dirty = true
+	c.mu.Unlock()
+}
+
+// start saves the state every interval until finish is called.
+func (c *checkpoint) start(interval time.Duration) {
+	if c == nil {
+		return
+	}
+	c.stop = make(chan struct{})
+	c.done = make(chan struct{})
+	go func() {
+		defer close(c.done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-c.stop:
+				return
+			case <-ticker.C:
+				if err := c.save(); err != nil {
+					fmt.Fprintf(os.Stderr, "Error saving %s: %v\n", c.path, err)
+				}
+			}
+		}
+	}()
+}
+
+// finish stops periodic saving. A completed run removes the state file so
+// it cannot be resumed again; otherwise the final state is saved.
+func (c *checkpoint) finish(completed bool) error {
+	if c == nil {
+		return nil
+	}
+	if c.stop != nil {
+		close(c.stop)
+		<-c.done
+	}
+	if completed {
+		err := os.Remove(c.path)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	c.mu.Lock()
+	c.dirty = true
+	c.mu.Unlock()
+	return c.save()
+}
+
+// save writes the state if it changed since the last save. The file is
+// replaced atomically so a crash mid-write keeps the previous checkpoint.
+func (c *checkpoint) save() error {
+	c.mu.Lock()
+	if !c.dirty {
+		c.mu.Unlock()
+		return nil
+	}
+	c.state.Saved = time.Now()
+	data, err := json.Marshal(c.state)
+	c.dirty = false
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := fileutil.WriteAtomic(c.path, data); err != nil {
+		// Try again on the next tick
+		c.mu.Lock()
+		c.dirty = true
+		c.mu.Unlock()
+		return err
+	}
+	return nil
+}
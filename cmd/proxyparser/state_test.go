@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ProxyParserGO/pkg/models"
+	"ProxyParserGO/pkg/output"
+)
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+func TestCheckpointResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.state")
+
+	good := models.Proxy{IP: "10.0.0.1", Port: "8080", Protocol: models.HTTP}
+	bad := models.Proxy{IP: "10.0.0.2", Port: "8080", Protocol: models.HTTP}
+	left := models.Proxy{IP: "10.0.0.3", Port: "8080", Protocol: models.HTTP}
+
+	cp, err := newCheckpoint(path, false)
+	if err != nil {
+		t.Fatalf("newCheckpoint: %v", err)
+	}
+	cp.addFetched([]models.Proxy{good, bad, left})
+	cp.addChecked(good, true)
+	cp.addChecked(bad, false)
+	if err := cp.finish(false); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+
+	resumed, err := newCheckpoint(path, true)
+	if err != nil {
+		t.Fatalf("resuming: %v", err)
+	}
+	if resumed.fetchDone() {
+		t.Error("fetchDone = true, want false")
+	}
+
+	var buf bytes.Buffer
+	out, _ := output.New("plain", nopCloser{&buf})
+	dedup := newDeduper("")
+	valid, remaining := resumed.restore(dedup, out)
+	out.Close()
+
+	if len(valid) != 1 || valid[0].IP != good.IP {
+		t.Errorf("valid = %v, want only %s", valid, good)
+	}
+	if len(remaining) != 1 || remaining[0].IP != left.IP {
+		t.Errorf("remaining = %v, want only %s", remaining, left)
+	}
+	if got := strings.TrimSpace(buf.String()); got != good.Address() {
+		t.Errorf("output = %q, want %q", got, good.Address())
+	}
+	if fresh := dedup.Add([]models.Proxy{bad}); len(fresh) != 0 {
+		t.Errorf("already fetched proxy %s was not deduplicated", bad)
+	}
+
+	// A completed run leaves nothing to resume
+	if err := resumed.finish(true); err != nil {
+		t.Fatalf("finish: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("state file still exists after a completed run: %v", err)
+	}
+	if _, err := newCheckpoint(path, true); err == nil {
+		t.Error("resuming without a state file succeeded, want an error")
+	}
+}
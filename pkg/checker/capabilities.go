@@ -0,0 +1,111 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"ProxyParserGO/pkg/models"
+)
+
+// Probes are the requests ProbeCapabilities sends through an HTTP proxy.
+type Probes struct {
+	ForwardURL  string // plain http URL requested in forward mode
+	HTTPSAddr   string // host:443 to open a CONNECT tunnel to
+	ConnectAddr string // host:port on another port to open a tunnel to
+}
+
+// DefaultProbes derives probes from a check target: the same URL over plain
+// http, and tunnels to its host on ports 443 and 80. Most proxies that
+// restrict CONNECT allow only 443.
+func DefaultProbes(targetURL string) (Probes, error) {
+	u, err := url.Parse(targetURL)
+	if err != nil || u.Hostname() == "" {
+		return Probes{}, fmt.Errorf("invalid target URL %q", targetURL)
+	}
+	host := u.Hostname()
+
+	forward := *u
+	forward.Scheme = "http"
+	forward.Host = host
+	if u.Scheme == "http" {
+		forward.Host = u.Host
+	}
+	return Probes{
+		ForwardURL:  forward.String(),
+		HTTPSAddr:   net.JoinHostPort(host, "443"),
+		ConnectAddr: net.JoinHostPort(host, "80"),
+	}, nil
+}
+
+// ProbeCapabilities finds out how an HTTP proxy can be used: whether it
+// forwards plain HTTP requests and opens CONNECT tunnels to port 443 and to
+// other ports. Probes left empty are skipped. Other protocols tunnel any
+// port by design and yield nil.
+func ProbeCapabilities(ctx context.Context, p models.Proxy, probes Probes, timeout time.Duration) []models.Capability {
+	if p.Protocol != models.HTTP {
+		return nil
+	}
+
+	var caps []models.Capability
+	if probes.ForwardURL != "" && canForward(ctx, p, probes.ForwardURL, timeout) {
+		caps = append(caps, models.CapForward)
+	}
+	if probes.HTTPSAddr != "" && canConnect(ctx, p, probes.HTTPSAddr, timeout) {
+		caps = append(caps, models.CapHTTPS)
+	}
+	if probes.ConnectAddr != "" && canConnect(ctx, p, probes.ConnectAddr, timeout) {
+		caps = append(caps, models.CapConnect)
+	}
+	return caps
+}
+
+// canForward reports whether the proxy forwards a plain GET of rawURL.
+// Redirects are not followed, as an https location would be fetched
+// through a tunnel instead. Any 2xx or 3xx answer counts as coming from the
+// origin; 4xx and 5xx cannot be told apart from the proxy's own refusals.
+func canForward(ctx context.Context, p models.Proxy, rawURL string, timeout time.Duration) bool {
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	client, err := newClient(p, timeout, &checkTrace{start: time.Now()}, nil)
+	if err != nil {
+		return false
+	}
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxBodySize))
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 400
+}
+
+// canConnect reports whether the proxy opens a CONNECT tunnel to addr.
+func canConnect(ctx context.Context, p models.Proxy, addr string, timeout time.Duration) bool {
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	dialer, err := NewDialer(p, timeout)
+	if err != nil {
+		return false
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
@@ -1,10 +1,19 @@
 package checker
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
+	"strings"
+	"sync"
 	"time"
 
 	"ProxyParserGO/pkg/models"
@@ -12,11 +21,121 @@ import (
 	"golang.org/x/net/proxy"
 )
 
-// Check validates a proxy against a target URL.
-// Returns success status and any error encountered.
-func Check(p models.Proxy, targetURL string, timeout time.Duration) (bool, error) {
-	var client *http.Client
+// FailureKind classifies why a check failed.
+type FailureKind string
 
+const (
+	FailureNone      FailureKind = ""
+	FailureDial      FailureKind = "dial"      // could not connect to the proxy
+	FailureHandshake FailureKind = "handshake" // proxy refused or broke the SOCKS/CONNECT handshake
+	FailureTLS       FailureKind = "tls"       // TLS with the target failed
+	FailureTimeout   FailureKind = "timeout"   // deadline exceeded at any stage
+	FailureStatus    FailureKind = "status"    // target answered with an unexpected status
+	FailureBody      FailureKind = "body"      // reading the response body failed
+
+	// Content validation failures, see Expect
+	FailureContent     FailureKind = "content"     // body lacks the expected text or pattern
+	FailureHeader      FailureKind = "header"      // an expected response header is missing or differs
+	FailureSize        FailureKind = "size"        // body smaller or larger than allowed
+	FailureHash        FailureKind = "hash"        // body differs from the known resource
+	FailureCertificate FailureKind = "certificate" // TLS certificate does not match a pin
+)
+
+// maxBodySize caps how much of the target response is read.
+const maxBodySize = 1 << 20
+
+// Result describes a single check of a proxy against a target URL.
+type Result struct {
+	OK bool
+
+	ConnectTime time.Duration // time to reach the target through the proxy
+	TTFB        time.Duration // time from start until the first response byte
+	Latency     time.Duration // total time including reading the body
+
+	StatusCode int
+	BytesRead  int64
+	ExitIP     string // IP reported by the target, if it echoes one
+
+	Failure FailureKind
+	Err     error
+}
+
+// checkTrace records how far a check got, so failures can be classified.
+type checkTrace struct {
+	mu           sync.Mutex
+	start        time.Time
+	tcpConnected bool
+	tunnelReady  bool
+	tlsStarted   bool
+	tlsDone      bool
+	connectTime  time.Duration
+	ttfb         time.Duration
+}
+
+func (t *checkTrace) set(f func(t *checkTrace)) {
+	t.mu.Lock()
+	f(t)
+	t.mu.Unlock()
+}
+
+// tracingDialer marks the trace once the TCP connection to the proxy is up.
+type tracingDialer struct {
+	dialer *net.Dialer
+	trace  *checkTrace
+}
+
+func (d *tracingDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *tracingDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := d.dialer.DialContext(ctx, network, addr)
+	if err == nil {
+		d.trace.set(func(t *checkTrace) { t.tcpConnected = true })
+	}
+	return conn, err
+}
+
+// Check validates a proxy against a target URL and reports timings, the
+// observed exit IP and, on failure, what went wrong.
+func Check(ctx context.Context, p models.Proxy, targetURL string, timeout time.Duration) Result {
+	res, _ := check(ctx, p, targetURL, timeout, nil)
+	return res
+}
+
+// CheckExpect is Check with the response also validated against expect,
+// to catch proxies serving login pages, injected or cached content, or
+// intercepting TLS.
+func CheckExpect(ctx context.Context, p models.Proxy, targetURL string, timeout time.Duration, expect *Expect) Result {
+	res, _ := check(ctx, p, targetURL, timeout, expect)
+	return res
+}
+
+// DetectVariants checks every variant of a SOCKS proxy's protocol, such as
+// socks4 and socks4a, against each target once, and returns those that pass
+// every target. Targets must name their host rather than an IP for the local
+// and remote DNS variants to differ. HTTP proxies have no variants and yield
+// nil.
+func DetectVariants(ctx context.Context, p models.Proxy, targets []Target, timeout time.Duration) []models.Protocol {
+	if p.Protocol.Base() == models.HTTP {
+		return nil
+	}
+
+	var working []models.Protocol
+	for _, v := range p.Protocol.Variants() {
+		variant := p
+		variant.Protocol = v
+		if res, _ := CheckTargets(ctx, variant, targets, 1, EveryTarget, timeout); res.OK {
+			working = append(working, v)
+		}
+	}
+	return working
+}
+
+// check performs the request behind Check and also returns the body read
+// from the target, which is nil unless the check succeeded. expect may be
+// nil.
+func check(ctx context.Context, p models.Proxy, targetURL string, timeout time.Duration, expect *Expect) (Result, []byte) {
 	if timeout == 0 {
 		timeout = 10 * time.Second
 	}
@@ -25,73 +144,218 @@ func Check(p models.Proxy, targetURL string, timeout time.Duration) (bool, error
 		targetURL = "https://www.google.com"
 	}
 
+	trace := &checkTrace{start: time.Now()}
+	client, err := newClient(p, timeout, trace, expect.tlsConfig())
+	if err != nil {
+		return Result{Failure: FailureDial, Err: err}, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, targetURL, nil)
+	if err != nil {
+		return Result{Failure: FailureStatus, Err: err}, nil
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		TLSHandshakeStart: func() {
+			trace.set(func(t *checkTrace) { t.tlsStarted = true })
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			trace.set(func(t *checkTrace) { t.tlsDone = err == nil })
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			trace.set(func(t *checkTrace) {
+				t.tunnelReady = true
+				t.connectTime = time.Since(t.start)
+			})
+		},
+		GotFirstResponseByte: func() {
+			trace.set(func(t *checkTrace) { t.ttfb = time.Since(t.start) })
+		},
+	}))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return trace.result(Result{Failure: classify(err, trace), Err: err}), nil
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, expect.readLimit()))
+	res := Result{
+		StatusCode: resp.StatusCode,
+		BytesRead:  int64(len(body)),
+		Latency:    time.Since(trace.start),
+	}
+
+	if resp.StatusCode != expect.status() {
+		res.Failure = FailureStatus
+		res.Err = fmt.Errorf("status code: %d", resp.StatusCode)
+		return trace.result(res), nil
+	}
+
+	if err != nil {
+		res.Failure = FailureBody
+		if isTimeout(err) {
+			res.Failure = FailureTimeout
+		}
+		res.Err = fmt.Errorf("read body: %w", err)
+		return trace.result(res), nil
+	}
+
+	if kind, err := expect.verify(resp, body); err != nil {
+		res.Failure = kind
+		res.Err = err
+		return trace.result(res), nil
+	}
+
+	res.OK = true
+	res.ExitIP = extractIP(body)
+	return trace.result(res), body
+}
+
+// result fills the timing fields of res from the trace.
+func (t *checkTrace) result(res Result) Result {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	res.ConnectTime = t.connectTime
+	res.TTFB = t.ttfb
+	if res.Latency == 0 {
+		res.Latency = time.Since(t.start)
+	}
+	return res
+}
+
+// newClient returns a client sending requests through p. tlsConfig may be
+// nil.
+func newClient(p models.Proxy, timeout time.Duration, trace *checkTrace, tlsConfig *tls.Config) (*http.Client, error) {
+	direct := &tracingDialer{
+		dialer: &net.Dialer{Timeout: timeout, KeepAlive: 30 * time.Second},
+		trace:  trace,
+	}
+
 	switch p.Protocol {
 	case models.HTTP:
-		proxyURL, err := url.Parse(fmt.Sprintf("http://%s:%s", p.IP, p.Port))
+		proxyURL, err := url.Parse("http://" + p.Address())
 		if err != nil {
-			return false, fmt.Errorf("url parse error: %w", err)
+			return nil, fmt.Errorf("url parse error: %w", err)
 		}
+		// The transport sends these as Proxy-Authorization: Basic
+		proxyURL.User = proxyUser(p)
 
 		transport := &http.Transport{
-			Proxy: http.ProxyURL(proxyURL),
-			DialContext: (&net.Dialer{
-				Timeout:   timeout,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
+			Proxy:             http.ProxyURL(proxyURL),
+			DialContext:       direct.DialContext,
+			TLSClientConfig:   tlsConfig,
 			DisableKeepAlives: true,
 		}
 
-		client = &http.Client{
+		return &http.Client{
 			Transport: transport,
 			Timeout:   timeout,
-		}
+		}, nil
 
-	case models.SOCKS5:
-		dialer, err := proxy.SOCKS5("tcp", fmt.Sprintf("%s:%s", p.IP, p.Port), nil, proxy.Direct)
+	case models.SOCKS4, models.SOCKS4A, models.SOCKS5, models.SOCKS5H:
+		dialer, err := newDialer(p, timeout, direct)
 		if err != nil {
-			return false, fmt.Errorf("socks5 dialer error: %w", err)
+			return nil, err
 		}
 
-		client = &http.Client{
+		return &http.Client{
 			Transport: &http.Transport{
-				Dial:              dialer.Dial,
+				DialContext:       dialer.DialContext,
+				TLSClientConfig:   tlsConfig,
 				DisableKeepAlives: true,
 			},
 			Timeout: timeout,
-		}
+		}, nil
+	}
 
-	case models.SOCKS4:
-		dialer := &SOCKS4Dialer{
-			ProxyIP:   p.IP,
-			ProxyPort: p.Port,
-			Timeout:   timeout,
-		}
+	return nil, fmt.Errorf("unknown protocol: %s", p.Protocol)
+}
 
-		client = &http.Client{
-			Transport: &http.Transport{
-				Dial:              dialer.Dial,
-				DisableKeepAlives: true,
-			},
-			Timeout: timeout,
-		}
+// proxyUser returns the credentials for an HTTP proxy URL, or nil.
+func proxyUser(p models.Proxy) *url.Userinfo {
+	if !p.HasAuth() {
+		return nil
+	}
+	return url.UserPassword(p.Username, p.Password)
+}
 
-	default:
-		return false, fmt.Errorf("unknown protocol: %s", p.Protocol)
+// socks5Auth returns the username/password auth for a SOCKS5 proxy, or nil.
+func socks5Auth(p models.Proxy) *proxy.Auth {
+	if !p.HasAuth() {
+		return nil
 	}
+	return &proxy.Auth{User: p.Username, Password: p.Password}
+}
 
-	if client == nil {
-		return false, fmt.Errorf("failed to create client")
+// classify maps a transport error onto a FailureKind using how far the
+// check progressed before failing.
+func classify(err error, trace *checkTrace) FailureKind {
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+
+	if isTimeout(err) {
+		return FailureTimeout
+	}
+	if errors.Is(err, ErrCertificatePin) {
+		return FailureCertificate
+	}
+	if !trace.tcpConnected {
+		return FailureDial
+	}
+	if isTLSError(err) || (trace.tlsStarted && !trace.tlsDone) {
+		return FailureTLS
+	}
+	if !trace.tunnelReady {
+		return FailureHandshake
 	}
+	return FailureBody
+}
 
-	resp, err := client.Get(targetURL)
-	if err != nil {
-		return false, err
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
 	}
-	defer resp.Body.Close()
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+func isTLSError(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuth x509.UnknownAuthorityError
+	var hostErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	return errors.As(err, &recordErr) ||
+		errors.As(err, &certErr) ||
+		errors.As(err, &unknownAuth) ||
+		errors.As(err, &hostErr) ||
+		errors.As(err, &invalidErr) ||
+		strings.Contains(err.Error(), "tls: ")
+}
 
-	if resp.StatusCode == http.StatusOK {
-		return true, nil
+// extractIP returns the client IP echoed by services such as api.ipify.org
+// (plain text) or httpbin.org/ip (JSON), or "" if the body is not an echo.
+func extractIP(body []byte) string {
+	text := strings.TrimSpace(string(body))
+	if ip := net.ParseIP(text); ip != nil {
+		return ip.String()
 	}
 
-	return false, fmt.Errorf("status code: %d", resp.StatusCode)
+	var fields map[string]any
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return ""
+	}
+	for _, key := range []string{"ip", "origin", "query", "client_ip"} {
+		v, ok := fields[key].(string)
+		if !ok {
+			continue
+		}
+		// httpbin reports "client, proxy" when headers are forwarded
+		first := strings.TrimSpace(strings.Split(v, ",")[0])
+		if ip := net.ParseIP(first); ip != nil {
+			return ip.String()
+		}
+	}
+	return ""
 }
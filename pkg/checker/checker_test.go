@@ -0,0 +1,484 @@
+package checker_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ProxyParserGO/pkg/checker"
+	"ProxyParserGO/pkg/models"
+	"ProxyParserGO/pkg/proxytest"
+)
+
+const exitIP = "203.0.113.7"
+
+func TestCheck(t *testing.T) {
+	target := proxytest.NewTarget(http.StatusOK, exitIP)
+	defer target.Close()
+	broken := proxytest.NewTarget(http.StatusInternalServerError, "oops")
+	defer broken.Close()
+	tlsTarget := proxytest.NewTLSTarget(http.StatusOK, exitIP)
+	defer tlsTarget.Close()
+
+	tests := []struct {
+		name      string
+		newProxy  proxytest.Factory
+		opts      proxytest.Options
+		user      string
+		pass      string
+		targetURL string
+		want      checker.FailureKind
+	}{
+		{name: "http ok", newProxy: proxytest.NewHTTPProxy, targetURL: target.URL},
+		{name: "socks4 ok", newProxy: proxytest.NewSOCKS4Proxy, targetURL: target.URL},
+		{name: "socks5 ok", newProxy: proxytest.NewSOCKS5Proxy, targetURL: target.URL},
+
+		{name: "http refused", newProxy: proxytest.NewHTTPProxy, opts: proxytest.Options{Mode: proxytest.ModeRefuse}, targetURL: target.URL, want: checker.FailureDial},
+		{name: "socks5 refused", newProxy: proxytest.NewSOCKS5Proxy, opts: proxytest.Options{Mode: proxytest.ModeRefuse}, targetURL: target.URL, want: checker.FailureDial},
+		{name: "http hang", newProxy: proxytest.NewHTTPProxy, opts: proxytest.Options{Mode: proxytest.ModeHang}, targetURL: target.URL, want: checker.FailureTimeout},
+		{name: "socks4 hang", newProxy: proxytest.NewSOCKS4Proxy, opts: proxytest.Options{Mode: proxytest.ModeHang}, targetURL: target.URL, want: checker.FailureTimeout},
+		{name: "socks5 hang", newProxy: proxytest.NewSOCKS5Proxy, opts: proxytest.Options{Mode: proxytest.ModeHang}, targetURL: target.URL, want: checker.FailureTimeout},
+
+		{name: "socks4 rejected", newProxy: proxytest.NewSOCKS4Proxy, opts: proxytest.Options{Mode: proxytest.ModeBadReply}, targetURL: target.URL, want: checker.FailureHandshake},
+		{name: "socks5 rejected", newProxy: proxytest.NewSOCKS5Proxy, opts: proxytest.Options{Mode: proxytest.ModeBadReply}, targetURL: target.URL, want: checker.FailureHandshake},
+		{name: "http connect rejected", newProxy: proxytest.NewHTTPProxy, opts: proxytest.Options{Mode: proxytest.ModeBadReply}, targetURL: tlsTarget.URL, want: checker.FailureHandshake},
+		{name: "http forward rejected", newProxy: proxytest.NewHTTPProxy, opts: proxytest.Options{Mode: proxytest.ModeBadReply}, targetURL: target.URL, want: checker.FailureStatus},
+
+		{name: "http wrong status", newProxy: proxytest.NewHTTPProxy, opts: proxytest.Options{Mode: proxytest.ModeWrongStatus}, targetURL: target.URL, want: checker.FailureStatus},
+		{name: "target error status", newProxy: proxytest.NewSOCKS5Proxy, targetURL: broken.URL, want: checker.FailureStatus},
+		{name: "untrusted certificate", newProxy: proxytest.NewSOCKS5Proxy, targetURL: tlsTarget.URL, want: checker.FailureTLS},
+
+		{name: "http auth missing", newProxy: proxytest.NewHTTPProxy, opts: proxytest.Options{Username: "u", Password: "p"}, targetURL: target.URL, want: checker.FailureStatus},
+		{name: "http auth", newProxy: proxytest.NewHTTPProxy, opts: proxytest.Options{Username: "u", Password: "p"}, user: "u", pass: "p", targetURL: target.URL},
+		{name: "http connect auth", newProxy: proxytest.NewHTTPProxy, opts: proxytest.Options{Username: "u", Password: "p"}, user: "u", pass: "p", targetURL: tlsTarget.URL, want: checker.FailureTLS},
+		{name: "socks5 auth missing", newProxy: proxytest.NewSOCKS5Proxy, opts: proxytest.Options{Username: "u", Password: "p"}, targetURL: target.URL, want: checker.FailureHandshake},
+		{name: "socks5 auth wrong", newProxy: proxytest.NewSOCKS5Proxy, opts: proxytest.Options{Username: "u", Password: "p"}, user: "u", pass: "x", targetURL: target.URL, want: checker.FailureHandshake},
+		{name: "socks5 auth", newProxy: proxytest.NewSOCKS5Proxy, opts: proxytest.Options{Username: "u", Password: "p"}, user: "u", pass: "p", targetURL: target.URL},
+		{name: "socks4 userid wrong", newProxy: proxytest.NewSOCKS4Proxy, opts: proxytest.Options{Username: "u"}, user: "x", targetURL: target.URL, want: checker.FailureHandshake},
+		{name: "socks4 userid", newProxy: proxytest.NewSOCKS4Proxy, opts: proxytest.Options{Username: "u"}, user: "u", targetURL: target.URL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := proxytest.Start(t, tt.newProxy, tt.opts)
+			p := s.Proxy()
+			p.Username, p.Password = tt.user, tt.pass
+
+			res := checker.Check(context.Background(), p, tt.targetURL, 500*time.Millisecond)
+
+			if res.Failure != tt.want {
+				t.Fatalf("Failure = %q, want %q (err: %v)", res.Failure, tt.want, res.Err)
+			}
+			if tt.want != checker.FailureNone {
+				if res.OK || res.Err == nil {
+					t.Fatalf("expected failure, got OK=%v err=%v", res.OK, res.Err)
+				}
+				return
+			}
+
+			if !res.OK {
+				t.Fatalf("expected success, got %v", res.Err)
+			}
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("StatusCode = %d, want 200", res.StatusCode)
+			}
+			if res.BytesRead != int64(len(exitIP)) {
+				t.Errorf("BytesRead = %d, want %d", res.BytesRead, len(exitIP))
+			}
+			if res.ExitIP != exitIP {
+				t.Errorf("ExitIP = %q, want %q", res.ExitIP, exitIP)
+			}
+			if res.ConnectTime <= 0 || res.TTFB < res.ConnectTime || res.Latency < res.TTFB {
+				t.Errorf("inconsistent timings: connect=%s ttfb=%s total=%s", res.ConnectTime, res.TTFB, res.Latency)
+			}
+		})
+	}
+}
+
+func TestCheckCancelled(t *testing.T) {
+	s := proxytest.Start(t, proxytest.NewSOCKS5Proxy, proxytest.Options{Mode: proxytest.ModeHang})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	res := checker.Check(ctx, s.Proxy(), "http://example.test/", 10*time.Second)
+	if res.OK {
+		t.Fatal("expected failure")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("cancellation took %s", elapsed)
+	}
+}
+
+func TestJudge(t *testing.T) {
+	j := proxytest.NewJudge()
+	defer j.Close()
+	s := proxytest.Start(t, proxytest.NewHTTPProxy, proxytest.Options{})
+
+	level, res := checker.Judge(context.Background(), s.Proxy(), j.URL, "198.51.100.1", time.Second)
+	if !res.OK {
+		t.Fatalf("judge failed: %v", res.Err)
+	}
+	if level != models.AnonymityElite {
+		t.Errorf("level = %q, want %q", level, models.AnonymityElite)
+	}
+
+	// Everything runs on loopback, so the proxy's address is also ours
+	level, _ = checker.Judge(context.Background(), s.Proxy(), j.URL, "127.0.0.1", time.Second)
+	if level != models.AnonymityTransparent {
+		t.Errorf("level = %q, want %q", level, models.AnonymityTransparent)
+	}
+}
+
+func TestDetectVariants(t *testing.T) {
+	target := proxytest.NewTarget(http.StatusOK, exitIP)
+	defer target.Close()
+	// A hostname lets local and remote resolution differ
+	targetURL := strings.Replace(target.URL, "127.0.0.1", "localhost", 1)
+
+	tests := []struct {
+		name     string
+		newProxy proxytest.Factory
+		mode     proxytest.Mode
+		want     []models.Protocol
+	}{
+		{"socks4", proxytest.NewSOCKS4Proxy, proxytest.ModeOK, []models.Protocol{models.SOCKS4, models.SOCKS4A}},
+		{"socks4a only", proxytest.NewSOCKS4Proxy, proxytest.ModeRemoteDNS, []models.Protocol{models.SOCKS4A}},
+		{"socks5", proxytest.NewSOCKS5Proxy, proxytest.ModeOK, []models.Protocol{models.SOCKS5, models.SOCKS5H}},
+		{"socks5h only", proxytest.NewSOCKS5Proxy, proxytest.ModeRemoteDNS, []models.Protocol{models.SOCKS5H}},
+		{"http", proxytest.NewHTTPProxy, proxytest.ModeOK, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := proxytest.Start(t, tt.newProxy, proxytest.Options{Mode: tt.mode})
+			got := checker.DetectVariants(context.Background(), s.Proxy(), []checker.Target{{Name: "target", URL: targetURL}}, time.Second)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("variants = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Remote variants leave the hostname to the proxy
+	s := proxytest.Start(t, proxytest.NewSOCKS4Proxy, proxytest.Options{})
+	p := s.Proxy()
+	p.Protocol = models.SOCKS4A
+	if res := checker.Check(context.Background(), p, targetURL, time.Second); !res.OK {
+		t.Fatalf("socks4a check failed: %v", res.Err)
+	}
+	if targets := s.Targets(); len(targets) != 1 || !strings.HasPrefix(targets[0], "localhost:") {
+		t.Errorf("proxy saw targets %v, want the unresolved hostname", targets)
+	}
+}
+
+func TestProbeCapabilities(t *testing.T) {
+	target := proxytest.NewTarget(http.StatusOK, exitIP)
+	defer target.Close()
+	other := proxytest.NewTarget(http.StatusOK, exitIP)
+	defer other.Close()
+
+	// target stands in for port 443 and other for any other port
+	httpsAddr := strings.TrimPrefix(target.URL, "http://")
+	_, httpsPort, _ := net.SplitHostPort(httpsAddr)
+	probes := checker.Probes{
+		ForwardURL:  target.URL,
+		HTTPSAddr:   httpsAddr,
+		ConnectAddr: strings.TrimPrefix(other.URL, "http://"),
+	}
+
+	tests := []struct {
+		name     string
+		newProxy proxytest.Factory
+		opts     proxytest.Options
+		want     []models.Capability
+	}{
+		{"open", proxytest.NewHTTPProxy, proxytest.Options{}, []models.Capability{models.CapForward, models.CapHTTPS, models.CapConnect}},
+		{"https only", proxytest.NewHTTPProxy, proxytest.Options{ConnectPorts: []string{httpsPort}}, []models.Capability{models.CapForward, models.CapHTTPS}},
+		{"tunnel only", proxytest.NewHTTPProxy, proxytest.Options{NoForward: true}, []models.Capability{models.CapHTTPS, models.CapConnect}},
+		{"forward only", proxytest.NewHTTPProxy, proxytest.Options{ConnectPorts: []string{"443"}}, []models.Capability{models.CapForward}},
+		{"rejecting", proxytest.NewHTTPProxy, proxytest.Options{Mode: proxytest.ModeBadReply}, nil},
+		{"socks5", proxytest.NewSOCKS5Proxy, proxytest.Options{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := proxytest.Start(t, tt.newProxy, tt.opts)
+			got := checker.ProbeCapabilities(context.Background(), s.Proxy(), probes, time.Second)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("capabilities = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProbeForwardRedirect(t *testing.T) {
+	tlsTarget := proxytest.NewTLSTarget(http.StatusOK, exitIP)
+	defer tlsTarget.Close()
+	// The origin sends plain http visitors to https, as many sites do
+	origin := httptest.NewServer(http.RedirectHandler(tlsTarget.URL, http.StatusMovedPermanently))
+	defer origin.Close()
+
+	probes := checker.Probes{ForwardURL: origin.URL}
+	tests := []struct {
+		name string
+		opts proxytest.Options
+		want []models.Capability
+	}{
+		{"tunnel only", proxytest.Options{NoForward: true}, nil},
+		{"forwarding", proxytest.Options{}, []models.Capability{models.CapForward}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := proxytest.Start(t, proxytest.NewHTTPProxy, tt.opts)
+			got := checker.ProbeCapabilities(context.Background(), s.Proxy(), probes, time.Second)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("capabilities = %v, want %v", got, tt.want)
+			}
+			// The redirect must not be followed through a tunnel
+			for _, target := range s.Targets() {
+				if target == strings.TrimPrefix(tlsTarget.URL, "https://") {
+					t.Errorf("proxy was asked to reach the redirect location %s", target)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultProbes(t *testing.T) {
+	got, err := checker.DefaultProbes("https://api.ipify.org/?format=text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := checker.Probes{
+		ForwardURL:  "http://api.ipify.org/?format=text",
+		HTTPSAddr:   "api.ipify.org:443",
+		ConnectAddr: "api.ipify.org:80",
+	}
+	if got != want {
+		t.Errorf("probes = %+v, want %+v", got, want)
+	}
+}
+
+func TestCheckExpect(t *testing.T) {
+	const body = `{"ip": "203.0.113.7"}`
+	target := proxytest.NewTarget(http.StatusOK, body)
+	defer target.Close()
+	tlsTarget := proxytest.NewTLSTarget(http.StatusOK, body)
+	defer tlsTarget.Close()
+
+	bodySum := sha256.Sum256([]byte(body))
+	certSum := sha256.Sum256(tlsTarget.Certificate().Raw)
+	pin := strings.ToUpper(hex.EncodeToString(certSum[:]))
+
+	tests := []struct {
+		name      string
+		targetURL string
+		expect    checker.Expect
+		want      checker.FailureKind
+	}{
+		{name: "contains", expect: checker.Expect{Contains: exitIP}},
+		{name: "missing text", expect: checker.Expect{Contains: "login"}, want: checker.FailureContent},
+		{name: "pattern", expect: checker.Expect{Pattern: regexp.MustCompile(`"ip":\s*"[\d.]+"`)}},
+		{name: "pattern mismatch", expect: checker.Expect{Pattern: regexp.MustCompile(`^<html`)}, want: checker.FailureContent},
+		{name: "header", expect: checker.Expect{Header: map[string]string{"content-type": "text/plain"}}},
+		{name: "header value differs", expect: checker.Expect{Header: map[string]string{"Content-Type": "application/json"}}, want: checker.FailureHeader},
+		{name: "header missing", expect: checker.Expect{Header: map[string]string{"X-Served-By": ""}}, want: checker.FailureHeader},
+		{name: "size", expect: checker.Expect{MinSize: 10, MaxSize: 100}},
+		{name: "too small", expect: checker.Expect{MinSize: 100}, want: checker.FailureSize},
+		{name: "too large", expect: checker.Expect{MaxSize: 10}, want: checker.FailureSize},
+		{name: "hash", expect: checker.Expect{SHA256: hex.EncodeToString(bodySum[:])}},
+		{name: "hash mismatch", expect: checker.Expect{SHA256: strings.Repeat("0", 64)}, want: checker.FailureHash},
+		{name: "pinned certificate", targetURL: tlsTarget.URL, expect: checker.Expect{CertSHA256: []string{pin}}},
+		{name: "pin mismatch", targetURL: tlsTarget.URL, expect: checker.Expect{CertSHA256: []string{strings.Repeat("ab", 32)}}, want: checker.FailureCertificate},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := proxytest.Start(t, proxytest.NewHTTPProxy, proxytest.Options{})
+			targetURL := tt.targetURL
+			if targetURL == "" {
+				targetURL = target.URL
+			}
+
+			res := checker.CheckExpect(context.Background(), s.Proxy(), targetURL, 2*time.Second, &tt.expect)
+			if res.Failure != tt.want {
+				t.Errorf("failure = %q (%v), want %q", res.Failure, res.Err, tt.want)
+			}
+			if res.OK != (tt.want == checker.FailureNone) {
+				t.Errorf("OK = %v, want %v", res.OK, tt.want == checker.FailureNone)
+			}
+		})
+	}
+}
+
+func TestParseFingerprint(t *testing.T) {
+	want := strings.Repeat("ab", 32)
+	got, err := checker.ParseFingerprint(strings.TrimSuffix(strings.Repeat("AB:", 32), ":"))
+	if err != nil || got != want {
+		t.Errorf("ParseFingerprint = %q, %v, want %q", got, err, want)
+	}
+	if _, err := checker.ParseFingerprint("abcd"); err == nil {
+		t.Error("ParseFingerprint accepted a short fingerprint")
+	}
+}
+
+func TestCheckTargets(t *testing.T) {
+	good := proxytest.NewTarget(http.StatusOK, exitIP)
+	defer good.Close()
+	bad := proxytest.NewTarget(http.StatusOK, "<html>Please log in</html>")
+	defer bad.Close()
+
+	gone := proxytest.NewTarget(http.StatusNotFound, "not found")
+	defer gone.Close()
+
+	// Fails every other request, starting with the first of each test
+	var flakyRequests atomic.Int32
+	flaky := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if flakyRequests.Add(1)%2 == 1 {
+			io.WriteString(w, "<html>Please log in</html>")
+			return
+		}
+		io.WriteString(w, exitIP)
+	}))
+	defer flaky.Close()
+
+	goodTarget := checker.Target{Name: "good", URL: good.URL}
+	badTarget := checker.Target{Name: "bad", URL: bad.URL, Expect: checker.Expect{Contains: exitIP}}
+	flakyTarget := checker.Target{Name: "flaky", URL: flaky.URL, Expect: checker.Expect{Contains: exitIP}}
+	// A target may expect a status other than 200
+	goneTarget := checker.Target{Name: "gone", URL: gone.URL, Expect: checker.Expect{Status: http.StatusNotFound, Pattern: regexp.MustCompile(`^not found$`)}}
+
+	tests := []struct {
+		name    string
+		targets []checker.Target
+		rounds  int
+		minPass int
+		wantOK  bool
+		want    string // attempts/passed per target
+	}{
+		{name: "all pass", targets: []checker.Target{goodTarget, goneTarget}, rounds: 2, wantOK: true, want: "good 2/2 gone 2/2"},
+		{name: "all fails a flaky target", targets: []checker.Target{flakyTarget, goodTarget}, rounds: 2, want: "flaky 1/0 good 1/1"},
+		{name: "all stops after a failed round", targets: []checker.Target{goodTarget, badTarget}, rounds: 3, want: "good 1/1 bad 1/0"},
+		{name: "every target", targets: []checker.Target{goodTarget, goneTarget}, rounds: 2, minPass: checker.EveryTarget, wantOK: true, want: "good 1/1 gone 1/1"},
+		{name: "every target retries", targets: []checker.Target{flakyTarget, goodTarget}, rounds: 2, minPass: checker.EveryTarget, wantOK: true, want: "flaky 2/1 good 1/1"},
+		{name: "every target fails", targets: []checker.Target{goodTarget, badTarget}, rounds: 2, minPass: checker.EveryTarget, want: "good 1/1 bad 2/0"},
+		{name: "1 of 4 checks every target", targets: []checker.Target{goodTarget, badTarget}, rounds: 2, minPass: 1, wantOK: true, want: "good 1/1 bad 1/0"},
+		{name: "2 of 4", targets: []checker.Target{goodTarget, badTarget}, rounds: 2, minPass: 2, wantOK: true, want: "good 2/2 bad 1/0"},
+		{name: "3 of 4", targets: []checker.Target{goodTarget, badTarget}, rounds: 2, minPass: 3, want: "good 2/2 bad 2/0"},
+		{name: "4 of 4 checks every target", targets: []checker.Target{badTarget, goodTarget}, rounds: 2, minPass: 4, want: "bad 1/0 good 1/1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flakyRequests.Store(0)
+			s := proxytest.Start(t, proxytest.NewHTTPProxy, proxytest.Options{})
+			res, results := checker.CheckTargets(context.Background(), s.Proxy(), tt.targets, tt.rounds, tt.minPass, time.Second)
+			if res.OK != tt.wantOK {
+				t.Errorf("OK = %v (%s: %v), want %v", res.OK, res.Failure, res.Err, tt.wantOK)
+			}
+			if !res.OK && res.Failure != checker.FailureContent {
+				t.Errorf("failure = %q, want %q", res.Failure, checker.FailureContent)
+			}
+			if res.OK && res.ExitIP != exitIP {
+				t.Errorf("exit IP = %q, want %s", res.ExitIP, exitIP)
+			}
+
+			var got []string
+			for _, r := range results {
+				got = append(got, fmt.Sprintf("%s %d/%d", r.Target, r.Attempts, r.Passed))
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("results = %s, want %s", strings.Join(got, " "), tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTargets(t *testing.T) {
+	targets, err := checker.ParseTargets([]byte(`
+targets:
+  - name: ipify
+    url: https://api.ipify.org
+    pattern: '^\d+\.\d+\.\d+\.\d+$'
+    max_size: 64
+  - url: https://example.com/
+    status: 200
+    header: {Content-Type: text/html}
+    cert_sha256: ["` + strings.TrimSuffix(strings.Repeat("AB:", 32), ":") + `"]
+`))
+	if err != nil {
+		t.Fatalf("ParseTargets: %v", err)
+	}
+	if len(targets) != 2 {
+		t.Fatalf("got %d targets, want 2", len(targets))
+	}
+	if targets[0].Name != "ipify" || targets[0].Expect.Pattern == nil || targets[0].Expect.MaxSize != 64 {
+		t.Errorf("first target = %+v", targets[0])
+	}
+	if targets[1].Name != "https://example.com/" || targets[1].Expect.CertSHA256[0] != strings.Repeat("ab", 32) {
+		t.Errorf("second target = %+v, want the URL as name and a normalized pin", targets[1])
+	}
+
+	for _, bad := range []string{
+		"targets: []",
+		"targets: [{url: ftp://example.com}]",
+		"targets: [{url: https://example.com, pattern: '('}]",
+		"targets: [{url: https://example.com, sha256: abc}]",
+		"targets: [{name: a, url: https://a.example}, {name: a, url: https://b.example}]",
+	} {
+		if _, err := checker.ParseTargets([]byte(bad)); err == nil {
+			t.Errorf("ParseTargets(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestHTTPConnectDialerCancel(t *testing.T) {
+	s := proxytest.Start(t, proxytest.NewHTTPProxy, proxytest.Options{Mode: proxytest.ModeHang})
+	p := s.Proxy()
+	d := &checker.HTTPConnectDialer{ProxyIP: p.IP, ProxyPort: p.Port, Timeout: 10 * time.Second}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := d.DialContext(ctx, "tcp", "192.0.2.1:80")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("cancelled dial took %s", elapsed)
+	}
+
+	d.Timeout = 50 * time.Millisecond
+	if _, err := d.Dial("tcp", "192.0.2.1:80"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestSOCKS4Dialer(t *testing.T) {
+	target := proxytest.NewTarget(http.StatusOK, exitIP)
+	defer target.Close()
+	s := proxytest.Start(t, proxytest.NewSOCKS4Proxy, proxytest.Options{})
+	p := s.Proxy()
+
+	d := &checker.SOCKS4Dialer{ProxyIP: p.IP, ProxyPort: p.Port, Timeout: time.Second}
+	client := &http.Client{Transport: &http.Transport{DialContext: d.DialContext}, Timeout: 5 * time.Second}
+	resp, err := client.Get(target.URL)
+	if err != nil {
+		t.Fatalf("GET through the deprecated dialer: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != exitIP {
+		t.Errorf("body = %q, want %q", body, exitIP)
+	}
+}
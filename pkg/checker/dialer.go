@@ -0,0 +1,209 @@
+package checker
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"ProxyParserGO/pkg/models"
+	"ProxyParserGO/pkg/socks4"
+
+	"golang.org/x/net/proxy"
+)
+
+// HTTPConnectDialer opens tunnels through an HTTP proxy using CONNECT.
+type HTTPConnectDialer struct {
+	ProxyIP   string
+	ProxyPort string
+	Username  string
+	Password  string
+	Timeout   time.Duration
+
+	// Forward is used to reach the proxy. Nil means a direct connection.
+	Forward proxy.Dialer
+}
+
+func (d *HTTPConnectDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *HTTPConnectDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+	conn, err := dialForward(ctx, d.Forward, net.JoinHostPort(d.ProxyIP, d.ProxyPort), d.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if d.Username != "" || d.Password != "" {
+		auth := base64.StdEncoding.EncodeToString([]byte(d.Username + ":" + d.Password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+
+	stop := watch(ctx, conn)
+	var header []byte
+	if err = req.Write(conn); err == nil {
+		// The proxy may start sending tunnel data right after its reply, so
+		// only the response header may be consumed from the connection.
+		header, err = readResponseHeader(conn)
+	}
+	if err = stop(err); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("CONNECT response: %w", err)
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(header)), req)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("CONNECT response: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("CONNECT failed: %s", resp.Status)
+	}
+	return conn, nil
+}
+
+// readResponseHeader reads up to and including the blank line ending an
+// HTTP response header, one byte at a time.
+func readResponseHeader(conn net.Conn) ([]byte, error) {
+	const maxHeader = 16 << 10
+
+	buf := make([]byte, 0, 256)
+	b := make([]byte, 1)
+	for len(buf) < maxHeader {
+		if _, err := io.ReadFull(conn, b); err != nil {
+			return nil, err
+		}
+		buf = append(buf, b[0])
+		if bytes.HasSuffix(buf, []byte("\r\n\r\n")) {
+			return buf, nil
+		}
+	}
+	return nil, errors.New("response header too large")
+}
+
+// watch makes blocking I/O on conn return once ctx is done, as in package
+// socks4. The returned function ends the watch and, given the error of that
+// I/O, reports ctx's error instead when ctx interrupted it.
+func watch(ctx context.Context, conn net.Conn) func(error) error {
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	return func(err error) error {
+		if !stop() && err == nil {
+			// The deadline may be set after it is cleared below
+			err = ctx.Err()
+		}
+		conn.SetDeadline(time.Time{})
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+}
+
+// NewDialer returns a dialer that opens TCP connections through p.
+func NewDialer(p models.Proxy, timeout time.Duration) (proxy.ContextDialer, error) {
+	return newDialer(p, timeout, nil)
+}
+
+func newDialer(p models.Proxy, timeout time.Duration, forward proxy.Dialer) (proxy.ContextDialer, error) {
+	if forward == nil {
+		forward = &net.Dialer{Timeout: timeout}
+	}
+
+	switch p.Protocol {
+	case models.HTTP:
+		return &HTTPConnectDialer{
+			ProxyIP:   p.IP,
+			ProxyPort: p.Port,
+			Username:  p.Username,
+			Password:  p.Password,
+			Timeout:   timeout,
+			Forward:   forward,
+		}, nil
+	case models.SOCKS5, models.SOCKS5H:
+		dialer, err := proxy.SOCKS5("tcp", net.JoinHostPort(p.IP, p.Port), socks5Auth(p), forward)
+		if err != nil {
+			return nil, fmt.Errorf("socks5 dialer error: %w", err)
+		}
+		// The SOCKS5 client passes hostnames on, so plain SOCKS5 resolves
+		// them first
+		if p.Protocol == models.SOCKS5 {
+			return &localDNSDialer{dialer.(proxy.ContextDialer)}, nil
+		}
+		return dialer.(proxy.ContextDialer), nil
+	case models.SOCKS4, models.SOCKS4A:
+		return &socks4.Dialer{
+			ProxyAddr: net.JoinHostPort(p.IP, p.Port),
+			UserID:    p.Username,
+			RemoteDNS: p.Protocol == models.SOCKS4A,
+			Timeout:   timeout,
+			Forward:   forward,
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown protocol: %s", p.Protocol)
+}
+
+// localDNSDialer resolves hostnames before handing addresses to a proxy
+// dialer, so the proxy never sees them.
+type localDNSDialer struct {
+	proxy.ContextDialer
+}
+
+func (d *localDNSDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+func (d *localDNSDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if net.ParseIP(host) == nil {
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+		if err != nil {
+			return nil, err
+		}
+		ip := ips[0]
+		for _, candidate := range ips {
+			if candidate.To4() != nil {
+				ip = candidate
+				break
+			}
+		}
+		addr = net.JoinHostPort(ip.String(), port)
+	}
+	return d.ContextDialer.DialContext(ctx, network, addr)
+}
+
+// dialForward connects to addr through forward, honouring ctx when the
+// forward dialer supports it.
+func dialForward(ctx context.Context, forward proxy.Dialer, addr string, timeout time.Duration) (net.Conn, error) {
+	if forward == nil {
+		forward = &net.Dialer{Timeout: timeout}
+	}
+	if cd, ok := forward.(proxy.ContextDialer); ok {
+		return cd.DialContext(ctx, "tcp", addr)
+	}
+	return forward.Dial("tcp", addr)
+}
@@ -0,0 +1,141 @@
+package checker
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ErrCertificatePin is returned when the target's TLS certificate matches
+// none of the pinned fingerprints, a sign of a proxy intercepting TLS.
+var ErrCertificatePin = errors.New("certificate does not match any pinned fingerprint")
+
+// Expect describes a valid response from the target. Zero fields other
+// than Status are not checked. Each kind of mismatch is reported with its
+// own FailureKind.
+type Expect struct {
+	Status int // expected status code, default 200
+
+	Contains string         // text the body must contain
+	Pattern  *regexp.Regexp // pattern the body must match
+
+	// Header maps response header names to text their value must
+	// contain. An empty value only requires the header to be present.
+	Header map[string]string
+
+	MinSize int64 // minimum body size in bytes
+	MaxSize int64 // maximum body size in bytes
+
+	// SHA256 is the hex SHA-256 of the whole body, for a static resource
+	// that never changes.
+	SHA256 string
+
+	// CertSHA256 pins the target's leaf certificate by the hex SHA-256 of
+	// its DER encoding. When set, a matching certificate is accepted
+	// without checking it against the system roots, and any other fails
+	// with ErrCertificatePin.
+	CertSHA256 []string
+}
+
+// ParseFingerprint normalizes a hex SHA-256 fingerprint, accepting upper
+// case and colon separators as printed by openssl.
+func ParseFingerprint(s string) (string, error) {
+	hexStr := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(s), ":", ""))
+	if b, err := hex.DecodeString(hexStr); err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("invalid SHA-256 fingerprint: %q", s)
+	}
+	return hexStr, nil
+}
+
+// status returns the expected status code.
+func (e *Expect) status() int {
+	if e == nil || e.Status == 0 {
+		return http.StatusOK
+	}
+	return e.Status
+}
+
+// readLimit returns how much of the body to read: enough to tell that it
+// exceeds MaxSize.
+func (e *Expect) readLimit() int64 {
+	if e == nil || e.MaxSize <= 0 {
+		return maxBodySize
+	}
+	return e.MaxSize + 1
+}
+
+// tlsConfig returns the TLS settings enforcing the certificate pins, or nil
+// to use the defaults.
+func (e *Expect) tlsConfig() *tls.Config {
+	if e == nil || len(e.CertSHA256) == 0 {
+		return nil
+	}
+	pins := make([]string, 0, len(e.CertSHA256))
+	for _, pin := range e.CertSHA256 {
+		if fp, err := ParseFingerprint(pin); err == nil {
+			pins = append(pins, fp)
+		}
+	}
+	return &tls.Config{
+		// The pin replaces verification against the system roots
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return ErrCertificatePin
+			}
+			sum := sha256.Sum256(cs.PeerCertificates[0].Raw)
+			if !slices.Contains(pins, hex.EncodeToString(sum[:])) {
+				return fmt.Errorf("%w: got %x", ErrCertificatePin, sum)
+			}
+			return nil
+		},
+	}
+}
+
+// verify checks a response with the expected status and its body,
+// returning the failure kind and an error for the first mismatch.
+func (e *Expect) verify(resp *http.Response, body []byte) (FailureKind, error) {
+	if e == nil {
+		return FailureNone, nil
+	}
+
+	size := int64(len(body))
+	if e.MaxSize > 0 && size > e.MaxSize {
+		return FailureSize, fmt.Errorf("body larger than %d bytes", e.MaxSize)
+	}
+	if size < e.MinSize {
+		return FailureSize, fmt.Errorf("body of %d bytes, want at least %d", size, e.MinSize)
+	}
+
+	for name, want := range e.Header {
+		values, ok := resp.Header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			return FailureHeader, fmt.Errorf("missing header %s", name)
+		}
+		if want != "" && !strings.Contains(strings.Join(values, ", "), want) {
+			return FailureHeader, fmt.Errorf("header %s is %q, want %q", name, strings.Join(values, ", "), want)
+		}
+	}
+
+	if e.Contains != "" && !bytes.Contains(body, []byte(e.Contains)) {
+		return FailureContent, fmt.Errorf("body does not contain %q", e.Contains)
+	}
+	if e.Pattern != nil && !e.Pattern.Match(body) {
+		return FailureContent, fmt.Errorf("body does not match %q", e.Pattern)
+	}
+
+	if e.SHA256 != "" {
+		sum := sha256.Sum256(body)
+		if got := hex.EncodeToString(sum[:]); !strings.EqualFold(got, e.SHA256) {
+			return FailureHash, fmt.Errorf("body SHA-256 %s, want %s", got, e.SHA256)
+		}
+	}
+	return FailureNone, nil
+}
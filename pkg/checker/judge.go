@@ -0,0 +1,139 @@
+package checker
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"ProxyParserGO/pkg/judge"
+	"ProxyParserGO/pkg/models"
+)
+
+// leakHeaders are added by proxies that do not hide themselves.
+var leakHeaders = []string{
+	"X-Forwarded-For",
+	"Via",
+	"Forwarded",
+	"X-Real-Ip",
+	"X-Proxy-Id",
+	"Client-Ip",
+	"Proxy-Connection",
+}
+
+// RealIP asks the judge for our own address by calling it without a proxy.
+func RealIP(ctx context.Context, judgeURL string, timeout time.Duration) (string, error) {
+	client := &http.Client{Timeout: timeout}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, judgeURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("judge returned status: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return "", err
+	}
+	echo, err := parseJudge(body)
+	if err != nil {
+		return "", err
+	}
+	if echo.IP == "" {
+		return "", errors.New("judge did not report a client IP")
+	}
+	return echo.IP, nil
+}
+
+// Judge requests judgeURL through the proxy and classifies its anonymity by
+// what the judge saw. realIP is our own address as returned by RealIP.
+func Judge(ctx context.Context, p models.Proxy, judgeURL, realIP string, timeout time.Duration) (models.Anonymity, Result) {
+	res, body := check(ctx, p, judgeURL, timeout, nil)
+	if !res.OK {
+		return models.AnonymityUnknown, res
+	}
+
+	echo, err := parseJudge(body)
+	if err != nil {
+		res.OK = false
+		res.Failure = FailureBody
+		res.Err = fmt.Errorf("judge response: %w", err)
+		return models.AnonymityUnknown, res
+	}
+
+	res.ExitIP = echo.IP
+	return classifyAnonymity(realIP, echo), res
+}
+
+func classifyAnonymity(realIP string, echo judge.Response) models.Anonymity {
+	if realIP != "" {
+		if echo.IP == realIP {
+			return models.AnonymityTransparent
+		}
+		for _, values := range echo.Headers {
+			for _, v := range values {
+				if strings.Contains(v, realIP) {
+					return models.AnonymityTransparent
+				}
+			}
+		}
+	}
+
+	for _, name := range leakHeaders {
+		if echo.Headers.Get(name) != "" {
+			return models.AnonymityAnonymous
+		}
+	}
+	return models.AnonymityElite
+}
+
+// parseJudge accepts the JSON format served by pkg/judge as well as the
+// "KEY = value" listing produced by the common azenv.php judges.
+func parseJudge(body []byte) (judge.Response, error) {
+	var echo judge.Response
+	if err := json.Unmarshal(body, &echo); err == nil {
+		if echo.Headers == nil {
+			echo.Headers = http.Header{}
+		}
+		return echo, nil
+	}
+
+	echo = judge.Response{Headers: http.Header{}}
+	scanner := bufio.NewScanner(strings.NewReader(string(body)))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " = ")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		switch {
+		case key == "REMOTE_ADDR":
+			echo.IP = value
+		case strings.HasPrefix(key, "HTTP_"):
+			name := strings.ReplaceAll(strings.TrimPrefix(key, "HTTP_"), "_", "-")
+			echo.Headers.Add(textproto.CanonicalMIMEHeaderKey(strings.ToLower(name)), value)
+		}
+	}
+
+	if echo.IP == "" || net.ParseIP(echo.IP) == nil {
+		return judge.Response{}, errors.New("unrecognised judge response")
+	}
+	return echo, nil
+}
@@ -0,0 +1,236 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"time"
+
+	"ProxyParserGO/pkg/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Target is a URL proxies are checked against, with what a valid response
+// looks like.
+type Target struct {
+	Name   string // identifies the target in results
+	URL    string
+	Expect Expect
+}
+
+// Check checks p against the target.
+func (t Target) Check(ctx context.Context, p models.Proxy, timeout time.Duration) Result {
+	return CheckExpect(ctx, p, t.URL, timeout, &t.Expect)
+}
+
+// TargetConfig describes a target in a targets file.
+type TargetConfig struct {
+	Name string `yaml:"name" json:"name"` // default: the URL
+	URL  string `yaml:"url" json:"url"`
+
+	Status     int               `yaml:"status" json:"status"`
+	Contains   string            `yaml:"contains" json:"contains"`
+	Pattern    string            `yaml:"pattern" json:"pattern"`
+	Header     map[string]string `yaml:"header" json:"header"`
+	MinSize    int64             `yaml:"min_size" json:"min_size"`
+	MaxSize    int64             `yaml:"max_size" json:"max_size"`
+	SHA256     string            `yaml:"sha256" json:"sha256"`
+	CertSHA256 []string          `yaml:"cert_sha256" json:"cert_sha256"`
+}
+
+type targetsFile struct {
+	Targets []TargetConfig `yaml:"targets" json:"targets"`
+}
+
+// Target validates the entry and builds the Target it describes.
+func (c TargetConfig) Target() (Target, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Target{}, fmt.Errorf("invalid url: %q", c.URL)
+	}
+	if c.Status != 0 && (c.Status < 100 || c.Status > 599) {
+		return Target{}, fmt.Errorf("invalid status: %d", c.Status)
+	}
+	if c.MinSize < 0 || c.MaxSize < 0 || (c.MaxSize > 0 && c.MinSize > c.MaxSize) {
+		return Target{}, errors.New("min_size and max_size must not be negative, and min_size not above max_size")
+	}
+
+	t := Target{
+		Name: c.Name,
+		URL:  c.URL,
+		Expect: Expect{
+			Status:   c.Status,
+			Contains: c.Contains,
+			Header:   c.Header,
+			MinSize:  c.MinSize,
+			MaxSize:  c.MaxSize,
+		},
+	}
+	if t.Name == "" {
+		t.Name = c.URL
+	}
+	if c.Pattern != "" {
+		if t.Expect.Pattern, err = regexp.Compile(c.Pattern); err != nil {
+			return Target{}, fmt.Errorf("invalid pattern: %w", err)
+		}
+	}
+	if c.SHA256 != "" {
+		if t.Expect.SHA256, err = ParseFingerprint(c.SHA256); err != nil {
+			return Target{}, fmt.Errorf("invalid sha256: %w", err)
+		}
+	}
+	for _, pin := range c.CertSHA256 {
+		fp, err := ParseFingerprint(pin)
+		if err != nil {
+			return Target{}, fmt.Errorf("invalid cert_sha256: %w", err)
+		}
+		t.Expect.CertSHA256 = append(t.Expect.CertSHA256, fp)
+	}
+	return t, nil
+}
+
+// ParseTargets parses a YAML (or JSON) targets file. Invalid entries are
+// reported together, one error per entry.
+func ParseTargets(data []byte) ([]Target, error) {
+	var file targetsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if len(file.Targets) == 0 {
+		return nil, errors.New("no targets")
+	}
+
+	var targets []Target
+	var errs []error
+	names := make(map[string]bool)
+	for i, c := range file.Targets {
+		label := fmt.Sprintf("target #%d", i+1)
+		if c.Name != "" {
+			label += fmt.Sprintf(" (%s)", c.Name)
+		}
+		t, err := c.Target()
+		if err == nil && names[t.Name] {
+			err = fmt.Errorf("duplicate name %q", t.Name)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", label, err))
+			continue
+		}
+		names[t.Name] = true
+		targets = append(targets, t)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return targets, nil
+}
+
+// LoadTargets reads a targets file.
+func LoadTargets(path string) ([]Target, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := ParseTargets(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return targets, nil
+}
+
+// EveryTarget is the minPass of CheckTargets that passes a proxy when each
+// target succeeds in at least one of its rounds, rather than counting
+// attempts.
+const EveryTarget = -1
+
+// CheckTargets checks p against every target, rounds times over, and
+// passes it when at least minPass of those attempts succeed, or all of them
+// when minPass is zero. With EveryTarget it passes p when each target
+// succeeds in one of its rounds instead, and a target is not checked again
+// once it has. Every target is checked once; after that, checking stops as
+// soon as the outcome is settled.
+//
+// The Result is the last failed attempt when p fails. When it passes, the
+// Result is OK, with the average latency of the successful attempts and the
+// last exit IP seen. The per-target results cover the targets attempted.
+func CheckTargets(ctx context.Context, p models.Proxy, targets []Target, rounds, minPass int, timeout time.Duration) (Result, []models.TargetResult) {
+	total := rounds * len(targets)
+	if total == 0 {
+		return Result{OK: true}, nil
+	}
+	every := minPass == EveryTarget
+	switch {
+	case every:
+		minPass = 0 // a pass on each target is required instead
+	case minPass <= 0 || minPass > total:
+		minPass = total
+	}
+
+	results := make([]models.TargetResult, len(targets))
+	var last, failed Result
+	var latency time.Duration
+	passed, attempts := 0, 0
+
+rounds:
+	for round := 0; round < rounds; round++ {
+		for i, t := range targets {
+			if ctx.Err() != nil {
+				break rounds
+			}
+			r := &results[i]
+			if every && r.Passed > 0 {
+				continue
+			}
+			// Settled: enough passes, or too few attempts left to get them
+			if !every && round > 0 && (passed >= minPass || passed+total-attempts < minPass) {
+				break rounds
+			}
+
+			res := t.Check(ctx, p, timeout)
+			attempts++
+			r.Target = t.Name
+			r.Attempts++
+			if !res.OK {
+				failed = res
+				r.Failure = string(res.Failure)
+				continue
+			}
+			passed++
+			r.Passed++
+			r.Latency += res.Latency
+			latency += res.Latency
+			if res.ExitIP != "" {
+				last.ExitIP = res.ExitIP
+			}
+		}
+	}
+
+	var checked []models.TargetResult
+	ok := passed >= minPass
+	for _, r := range results {
+		if every && r.Passed == 0 {
+			ok = false
+		}
+		if r.Attempts == 0 {
+			continue
+		}
+		if r.Passed > 0 {
+			r.Latency /= time.Duration(r.Passed)
+		}
+		checked = append(checked, r)
+	}
+
+	if !ok {
+		if failed.Err == nil {
+			failed = Result{Failure: FailureTimeout, Err: ctx.Err()}
+		}
+		return failed, checked
+	}
+	last.OK = true
+	last.Latency = latency / time.Duration(passed)
+	return last, checked
+}
@@ -0,0 +1,230 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"ProxyParserGO/internal/fileutil"
+)
+
+// ErrNotCached is returned in offline mode for URLs missing from the cache.
+var ErrNotCached = errors.New("not in cache")
+
+// Cache stores source responses on disk. Cached pages are revalidated with
+// their ETag and Last-Modified, are served without a request while younger
+// than the refresh interval, and are the only source of pages when
+// offline.
+type Cache struct {
+	dir     string
+	offline bool
+
+	// Refresh is the default minimum time between requests for a URL,
+	// overridden per source with WithRefresh. Zero revalidates every time.
+	Refresh time.Duration
+}
+
+// cacheEntry is the metadata stored next to a cached body.
+type cacheEntry struct {
+	URL          string      `json:"url"`
+	ETag         string      `json:"etag,omitempty"`
+	LastModified string      `json:"last_modified,omitempty"`
+	Fetched      time.Time   `json:"fetched"`
+	Header       http.Header `json:"header"`
+}
+
+// NewCache opens the cache in dir, creating it unless offline.
+func NewCache(dir string, offline bool) (*Cache, error) {
+	if offline {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", dir)
+		}
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir, offline: offline}, nil
+}
+
+type refreshKey struct{}
+
+// WithRefresh sets the minimum refresh interval for requests made with the
+// returned context, overriding Cache.Refresh.
+func WithRefresh(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, refreshKey{}, d)
+}
+
+func (c *Cache) refresh(ctx context.Context) time.Duration {
+	if d, ok := ctx.Value(refreshKey{}).(time.Duration); ok {
+		return d
+	}
+	return c.Refresh
+}
+
+// transport returns a RoundTripper serving GET requests through the cache
+// and sending the rest to next.
+func (c *Cache) transport(next http.RoundTripper) http.RoundTripper {
+	return &cacheTransport{cache: c, next: next}
+}
+
+type cacheTransport struct {
+	cache *Cache
+	next  http.RoundTripper
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		if t.cache.offline {
+			return nil, fmt.Errorf("%s %s: %w", req.Method, req.URL, ErrNotCached)
+		}
+		return t.next.RoundTrip(req)
+	}
+
+	c := t.cache
+	url := req.URL.String()
+	entry, body, err := c.load(url)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	cached := err == nil
+
+	if c.offline {
+		if !cached {
+			return nil, fmt.Errorf("%s: %w", url, ErrNotCached)
+		}
+		return entry.response(req, body), nil
+	}
+	if cached && time.Since(entry.Fetched) < c.refresh(req.Context()) {
+		return entry.response(req, body), nil
+	}
+
+	out := req
+	if cached && (entry.ETag != "" || entry.LastModified != "") {
+		out = req.Clone(req.Context())
+		if entry.ETag != "" {
+			out.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			out.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	resp, err := t.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached:
+		resp.Body.Close()
+		entry.Fetched = time.Now()
+		if err := c.store(entry, nil); err != nil {
+			return nil, err
+		}
+		return entry.response(req, body), nil
+	case resp.StatusCode != http.StatusOK:
+		return resp, nil
+	}
+
+	// Bodies over maxPageSize are passed through without caching
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize+1))
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	if len(data) > maxPageSize {
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+		return resp, nil
+	}
+	resp.Body.Close()
+
+	entry = &cacheEntry{
+		URL:          url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		Fetched:      time.Now(),
+		Header:       resp.Header,
+	}
+	if err := c.store(entry, data); err != nil {
+		return nil, err
+	}
+	return entry.response(req, data), nil
+}
+
+// response builds the 200 response for a cached body.
+func (e *cacheEntry) response(req *http.Request, body []byte) *http.Response {
+	header := e.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func (c *Cache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:16]))
+}
+
+// load reads the entry and body cached for url.
+func (c *Cache) load(url string) (*cacheEntry, []byte, error) {
+	path := c.path(url)
+	meta, err := os.ReadFile(path + ".json")
+	if err != nil {
+		return nil, nil, err
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(meta, &entry); err != nil {
+		return nil, nil, fmt.Errorf("cache entry for %s: %w", url, err)
+	}
+	if entry.URL != url {
+		return nil, nil, os.ErrNotExist
+	}
+	body, err := os.ReadFile(path + ".body")
+	if err != nil {
+		return nil, nil, err
+	}
+	return &entry, body, nil
+}
+
+// store saves entry, and body unless it is nil. The body is written first
+// so an entry never points at a missing or partial body.
+func (c *Cache) store(entry *cacheEntry, body []byte) error {
+	path := c.path(entry.URL)
+	if body != nil {
+		if err := fileutil.WriteAtomic(path+".body", body); err != nil {
+			return err
+		}
+	}
+	meta, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return fileutil.WriteAtomic(path+".json", meta)
+}
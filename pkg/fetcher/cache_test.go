@@ -0,0 +1,85 @@
+package fetcher_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ProxyParserGO/pkg/fetcher"
+)
+
+func TestCache(t *testing.T) {
+	const list = "10.0.0.1:8080\n10.0.0.2:8080\n"
+	var requests, notModified atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			notModified.Add(1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(list))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	fetch := func(ctx context.Context, client *http.Client) (string, error) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/list.txt", nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	cache, err := fetcher.NewCache(dir, false)
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+	client := fetcher.NewClient(fetcher.ClientOptions{Cache: cache})
+	ctx := context.Background()
+
+	// Downloaded, then revalidated with the stored ETag
+	for i := 0; i < 2; i++ {
+		if body, err := fetch(ctx, client); err != nil || body != list {
+			t.Fatalf("fetch %d = %q, %v, want %q", i+1, body, err, list)
+		}
+	}
+	if r, nm := requests.Load(), notModified.Load(); r != 2 || nm != 1 {
+		t.Errorf("requests = %d with %d not modified, want 2 with 1", r, nm)
+	}
+
+	// Within the refresh interval no request is made
+	if body, err := fetch(fetcher.WithRefresh(ctx, time.Hour), client); err != nil || body != list {
+		t.Fatalf("fetch = %q, %v, want %q", body, err, list)
+	}
+	if r := requests.Load(); r != 2 {
+		t.Errorf("requests = %d after a fresh cache hit, want 2", r)
+	}
+
+	// Offline serves the cache and nothing else
+	offline, err := fetcher.NewCache(dir, true)
+	if err != nil {
+		t.Fatalf("NewCache offline: %v", err)
+	}
+	srv.Close()
+	client = fetcher.NewClient(fetcher.ClientOptions{Cache: offline})
+	if body, err := fetch(ctx, client); err != nil || body != list {
+		t.Fatalf("offline fetch = %q, %v, want %q", body, err, list)
+	}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/other.txt", nil)
+	if _, err := client.Do(req); !errors.Is(err, fetcher.ErrNotCached) {
+		t.Errorf("offline fetch of an uncached URL: err = %v, want ErrNotCached", err)
+	}
+	if _, err := fetcher.NewCache(t.TempDir()+"/missing", true); err == nil {
+		t.Error("NewCache offline with a missing directory succeeded, want an error")
+	}
+}
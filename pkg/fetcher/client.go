@@ -0,0 +1,309 @@
+package fetcher
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Defaults used by NewClient for unset ClientOptions.
+const (
+	DefaultRetries   = 3
+	DefaultBaseDelay = time.Second
+	DefaultMaxDelay  = 30 * time.Second
+)
+
+// ClientOptions configures the HTTP client shared by fetchers.
+type ClientOptions struct {
+	// Timeout bounds each attempt, including reading the body. Default 30s.
+	Timeout time.Duration
+
+	// Retries is how many times a request is repeated after a network
+	// error, 429 or 5xx response; negative disables retries. Delays grow
+	// exponentially from BaseDelay with full jitter, up to MaxDelay. A
+	// Retry-After header replaces the computed delay, and a response asking
+	// to wait longer than MaxDelay is returned as is.
+	Retries   int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+
+	// HostRate limits requests per second to each host and HostConcurrency
+	// the requests in flight to each host, until their bodies are closed.
+	// Zero means unlimited.
+	HostRate        float64
+	HostConcurrency int
+
+	UserAgent string      // empty keeps Go's default
+	Header    http.Header // added to every request that does not set them
+
+	// Cache, when set, serves and stores GET responses ahead of the
+	// retries and limits.
+	Cache *Cache
+
+	// Transport sends the requests not routed through an upstream proxy
+	// with WithUpstream, nil uses a clone of http.DefaultTransport.
+	Transport http.RoundTripper
+}
+
+// DefaultClient is used by fetchers without their own client. Replace it
+// before fetching to apply other options to every source.
+var DefaultClient = NewClient(ClientOptions{})
+
+// NewClient returns a client that retries failed requests, limits the
+// request rate and concurrency per host, sets the configured headers,
+// decodes gzip and brotli responses and, optionally, caches them.
+func NewClient(o ClientOptions) *http.Client {
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+	if o.Retries == 0 {
+		o.Retries = DefaultRetries
+	}
+	if o.BaseDelay <= 0 {
+		o.BaseDelay = DefaultBaseDelay
+	}
+	if o.MaxDelay <= 0 {
+		o.MaxDelay = DefaultMaxDelay
+	}
+	if o.Transport == nil {
+		o.Transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	o.Transport = &router{direct: o.Transport, timeout: o.Timeout}
+	var rt http.RoundTripper = &transport{opts: o, hosts: make(map[string]*hostLimiter)}
+	if o.Cache != nil {
+		rt = o.Cache.transport(rt)
+	}
+	return &http.Client{Transport: rt}
+}
+
+// transport implements ClientOptions on top of another RoundTripper.
+type transport struct {
+	opts ClientOptions
+
+	mu    sync.Mutex
+	hosts map[string]*hostLimiter
+}
+
+// hostLimiter paces and caps the requests to one host.
+type hostLimiter struct {
+	slots chan struct{} // nil without a concurrency cap
+
+	mu   sync.Mutex
+	next time.Time // earliest start of the next request
+}
+
+func (t *transport) host(name string) *hostLimiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	h, ok := t.hosts[name]
+	if !ok {
+		h = &hostLimiter{}
+		if t.opts.HostConcurrency > 0 {
+			h.slots = make(chan struct{}, t.opts.HostConcurrency)
+		}
+		t.hosts[name] = h
+	}
+	return h
+}
+
+// acquire waits for a concurrency slot and the host's rate limit. The
+// returned function releases the slot.
+func (t *transport) acquire(ctx context.Context, h *hostLimiter) (func(), error) {
+	release := func() {}
+	if h.slots != nil {
+		select {
+		case h.slots <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		release = func() { <-h.slots }
+	}
+
+	if t.opts.HostRate > 0 {
+		interval := time.Duration(float64(time.Second) / t.opts.HostRate)
+		h.mu.Lock()
+		now := time.Now()
+		start := h.next
+		if start.Before(now) {
+			start = now
+		}
+		h.next = start.Add(interval)
+		h.mu.Unlock()
+
+		if err := sleepContext(ctx, time.Until(start)); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	h := t.host(req.URL.Host)
+	retryable := req.Body == nil || req.GetBody != nil
+
+	for attempt := 0; ; attempt++ {
+		resp, err := t.attempt(req, h)
+		if ctx.Err() != nil {
+			return resp, err
+		}
+		if attempt >= t.opts.Retries || !retryable || !shouldRetry(resp, err) {
+			return resp, err
+		}
+
+		delay := t.backoff(attempt)
+		if resp != nil {
+			if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+				if d > t.opts.MaxDelay {
+					return resp, nil
+				}
+				delay = d
+			}
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+	}
+}
+
+// attempt sends req once, holding a host slot and the attempt timeout
+// until the response body is closed.
+func (t *transport) attempt(req *http.Request, h *hostLimiter) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.opts.Timeout)
+	release, err := t.acquire(ctx, h)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	done := func() {
+		release()
+		cancel()
+	}
+
+	out := req.Clone(ctx)
+	if t.opts.UserAgent != "" && out.Header.Get("User-Agent") == "" {
+		out.Header.Set("User-Agent", t.opts.UserAgent)
+	}
+	for name, values := range t.opts.Header {
+		if _, ok := out.Header[name]; !ok {
+			out.Header[name] = values
+		}
+	}
+	decode := out.Header.Get("Accept-Encoding") == ""
+	if decode {
+		out.Header.Set("Accept-Encoding", "gzip, br")
+	}
+
+	resp, err := t.opts.Transport.RoundTrip(out)
+	if err != nil {
+		done()
+		return nil, err
+	}
+
+	body := &releaseBody{ReadCloser: resp.Body, done: done}
+	resp.Body = body
+	if decode {
+		if err := decodeBody(resp, body); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// backoff returns the delay before retry number attempt+1: a random
+// duration up to BaseDelay doubled attempt times, capped at MaxDelay.
+func (t *transport) backoff(attempt int) time.Duration {
+	d := t.opts.MaxDelay
+	if attempt < 32 {
+		d = min(t.opts.BaseDelay<<attempt, t.opts.MaxDelay)
+	}
+	return time.Duration(rand.Int64N(int64(d) + 1))
+}
+
+// shouldRetry reports whether a failed attempt may succeed when repeated.
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return !errors.Is(err, context.Canceled)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP
+// date.
+func retryAfter(v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+// decodeBody replaces a gzip or brotli encoded body with the decoded
+// content.
+func decodeBody(resp *http.Response, raw io.ReadCloser) error {
+	var r io.Reader
+	switch strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding"))) {
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(raw)
+		if err != nil {
+			return err
+		}
+		r = gz
+	case "br":
+		r = brotli.NewReader(raw)
+	default:
+		return nil
+	}
+
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{r, raw}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+// releaseBody runs done once when the body is closed.
+type releaseBody struct {
+	io.ReadCloser
+	once sync.Once
+	done func()
+}
+
+func (b *releaseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
@@ -0,0 +1,161 @@
+package fetcher_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ProxyParserGO/pkg/fetcher"
+
+	"github.com/andybalholm/brotli"
+)
+
+func get(t *testing.T, client *http.Client, url string) (*http.Response, string) {
+	t.Helper()
+	resp, err := client.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading %s: %v", url, err)
+	}
+	return resp, string(body)
+}
+
+func TestClientRetry(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch attempts.Add(1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer srv.Close()
+
+	client := fetcher.NewClient(fetcher.ClientOptions{BaseDelay: time.Millisecond})
+	resp, body := get(t, client, srv.URL)
+	if resp.StatusCode != http.StatusOK || body != "ok" {
+		t.Errorf("got %s %q, want 200 \"ok\"", resp.Status, body)
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("attempts = %d, want 3", n)
+	}
+
+	// A server asking to wait longer than MaxDelay gets its answer back
+	attempts.Store(0)
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	if resp, _ := get(t, client, srv.URL); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("status = %s, want 429", resp.Status)
+	}
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("attempts = %d, want 1", n)
+	}
+
+	// Client errors are final
+	attempts.Store(0)
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	})
+	get(t, client, srv.URL)
+	if n := attempts.Load(); n != 1 {
+		t.Errorf("attempts = %d, want 1", n)
+	}
+}
+
+func TestClientHeadersAndDecoding(t *testing.T) {
+	const text = "10.0.0.1:8080\n"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ua := r.Header.Get("User-Agent"); ua != "test-agent" {
+			t.Errorf("User-Agent = %q, want test-agent", ua)
+		}
+		if key := r.Header.Get("X-Api-Key"); key != "secret" {
+			t.Errorf("X-Api-Key = %q, want secret", key)
+		}
+
+		var buf bytes.Buffer
+		switch r.URL.Path {
+		case "/gzip":
+			zw := gzip.NewWriter(&buf)
+			zw.Write([]byte(text))
+			zw.Close()
+			w.Header().Set("Content-Encoding", "gzip")
+		case "/br":
+			bw := brotli.NewWriter(&buf)
+			bw.Write([]byte(text))
+			bw.Close()
+			w.Header().Set("Content-Encoding", "br")
+		default:
+			buf.WriteString(text)
+		}
+		w.Write(buf.Bytes())
+	}))
+	defer srv.Close()
+
+	client := fetcher.NewClient(fetcher.ClientOptions{
+		UserAgent: "test-agent",
+		Header:    http.Header{"X-Api-Key": {"secret"}},
+	})
+	for _, path := range []string{"/plain", "/gzip", "/br"} {
+		resp, body := get(t, client, srv.URL+path)
+		if body != text {
+			t.Errorf("%s: body = %q, want %q", path, body, text)
+		}
+		if enc := resp.Header.Get("Content-Encoding"); enc != "" {
+			t.Errorf("%s: Content-Encoding = %q after decoding", path, enc)
+		}
+	}
+}
+
+func TestClientHostLimits(t *testing.T) {
+	var inFlight, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	client := fetcher.NewClient(fetcher.ClientOptions{HostRate: 50, HostConcurrency: 2})
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(t, client, srv.URL)
+		}()
+	}
+	wg.Wait()
+
+	if p := peak.Load(); p > 2 {
+		t.Errorf("peak concurrency = %d, want at most 2", p)
+	}
+	// 6 requests at 50/s start at least 100ms apart from first to last
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("6 requests took %s, want at least 100ms at 50 requests/s", elapsed)
+	}
+}
@@ -2,7 +2,9 @@ package fetcher
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
@@ -15,17 +17,35 @@ type Logger func(string)
 // ProxyCallback determines how found proxies are delivered
 type ProxyCallback func([]models.Proxy)
 
+// Fetcher retrieves proxies from a single source. Implementations must stop
+// issuing requests, sleeping and paginating once ctx is cancelled.
 type Fetcher interface {
-	Fetch(logger Logger, onProxies ProxyCallback) error
+	Fetch(ctx context.Context, logger Logger, onProxies ProxyCallback) error
+}
+
+// defaultTimeout bounds each request attempt of clients made by NewClient.
+const defaultTimeout = 30 * time.Second
+
+// maxPageSize bounds pages read whole into memory.
+const maxPageSize = 32 << 20
+
+// httpClient returns c, or DefaultClient when c is nil. Fetchers take an
+// optional client so tests can point them at local servers.
+func httpClient(c *http.Client) *http.Client {
+	if c != nil {
+		return c
+	}
+	return DefaultClient
 }
 
 // fetchURL is a helper function to fetch raw text content from a URL
-func fetchURL(url string) ([]string, error) {
-	client := http.Client{
-		Timeout: 30 * time.Second,
+func fetchURL(ctx context.Context, client *http.Client, url string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
 	}
 
-	resp, err := client.Get(url)
+	resp, err := httpClient(client).Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -43,3 +63,43 @@ func fetchURL(url string) ([]string, error) {
 
 	return lines, scanner.Err()
 }
+
+// fetchBody downloads url, refusing bodies larger than maxPageSize.
+func fetchBody(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status: %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxPageSize {
+		return nil, fmt.Errorf("page larger than %d bytes", maxPageSize)
+	}
+	return body, nil
+}
+
+// sleepContext pauses for d or until ctx is cancelled, whichever comes first.
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
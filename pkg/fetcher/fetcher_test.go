@@ -0,0 +1,595 @@
+package fetcher_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ProxyParserGO/pkg/fetcher"
+	"ProxyParserGO/pkg/models"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// The files in testdata are snapshots of the live sources, refreshed with
+// "proxyparser record-fixtures". Assertions only depend on the structure of
+// the pages so that re-recorded fixtures keep passing while parser
+// regressions do not.
+
+// serveFixtures starts a server that answers requests with the fixture
+// chosen by route, or 404 when route returns "".
+func serveFixtures(t *testing.T, route func(r *http.Request) string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := route(r)
+		if name == "" {
+			http.NotFound(w, r)
+			return
+		}
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Errorf("reading fixture: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// collect runs f and returns every proxy it delivers.
+func collect(t *testing.T, f fetcher.Fetcher) []models.Proxy {
+	t.Helper()
+
+	var mu sync.Mutex
+	var proxies []models.Proxy
+	err := f.Fetch(context.Background(), func(string) {}, func(batch []models.Proxy) {
+		mu.Lock()
+		proxies = append(proxies, batch...)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	return proxies
+}
+
+// checkProxies fails the test unless proxies is non-empty and every entry
+// is well formed.
+func checkProxies(t *testing.T, proxies []models.Proxy, source string) {
+	t.Helper()
+
+	if len(proxies) == 0 {
+		t.Fatal("no proxies parsed from fixture")
+	}
+	for _, p := range proxies {
+		if net.ParseIP(p.IP) == nil {
+			t.Errorf("%v: invalid IP %q", p, p.IP)
+		}
+		if port, err := strconv.Atoi(p.Port); err != nil || port < 1 || port > 65535 {
+			t.Errorf("%v: invalid port %q", p, p.Port)
+		}
+		if _, err := models.ParseProtocol(string(p.Protocol)); err != nil {
+			t.Errorf("%v: %v", p, err)
+		}
+		if p.Source != source {
+			t.Errorf("%v: source = %q, want %q", p, p.Source, source)
+		}
+	}
+}
+
+func TestTextFetcher(t *testing.T) {
+	// Lines naming a scheme keep it, the others get the configured protocol
+	for _, fixture := range []string{"text.txt", "proxyscrape.txt"} {
+		t.Run(fixture, func(t *testing.T) {
+			srv := serveFixtures(t, func(r *http.Request) string { return fixture })
+
+			f := &fetcher.TextFetcher{URL: srv.URL + "/list.txt", Protocol: models.SOCKS5, Source: "text", Client: srv.Client()}
+			proxies := collect(t, f)
+			checkProxies(t, proxies, "text")
+
+			schemes := fixtureSchemes(t, fixture)
+			for _, p := range proxies {
+				want := models.SOCKS5
+				if scheme, ok := schemes[p.Address()]; ok {
+					want = scheme
+				}
+				if p.Protocol != want {
+					t.Errorf("%v: protocol = %s, want %s", p, p.Protocol, want)
+				}
+			}
+		})
+	}
+}
+
+// fixtureSchemes maps the addresses on the lines of a text fixture that
+// name a scheme to the protocol it stands for.
+func fixtureSchemes(t *testing.T, name string) map[string]models.Protocol {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	schemes := make(map[string]models.Protocol)
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.Contains(line, "://") {
+			continue
+		}
+		if p, err := models.ParseProxy(line, ""); err == nil {
+			schemes[p.Address()] = p.Protocol
+		}
+	}
+	return schemes
+}
+
+func TestHTMLFetcher(t *testing.T) {
+	srv := serveFixtures(t, func(r *http.Request) string { return "free-proxy-list.html" })
+
+	f := &fetcher.HTMLFetcher{URL: srv.URL + "/ru/", Source: "fpl", Client: srv.Client()}
+	proxies := collect(t, f)
+	checkProxies(t, proxies, "fpl")
+
+	// The Https column reports CONNECT support
+	https := fixtureColumn(t, "free-proxy-list.html", "Https")
+	for _, p := range proxies {
+		want := https[p.IP] == "yes"
+		if got := p.Can(models.CapHTTPS); got != want {
+			t.Errorf("%v: reported https = %v, want %v", p, got, want)
+		}
+	}
+}
+
+// fixtureColumn maps the first cell of each row of the table in a fixture
+// to its cell in the column with the given header.
+func fixtureColumn(t *testing.T, name, header string) map[string]string {
+	t.Helper()
+	f, err := os.Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	doc, err := goquery.NewDocumentFromReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	column := -1
+	doc.Find("table thead th").Each(func(i int, th *goquery.Selection) {
+		if strings.EqualFold(strings.TrimSpace(th.Text()), header) {
+			column = i
+		}
+	})
+	if column < 0 {
+		t.Fatalf("%s: no %q column", name, header)
+	}
+
+	values := make(map[string]string)
+	doc.Find("table tbody tr").Each(func(_ int, tr *goquery.Selection) {
+		cells := tr.Children()
+		key := strings.TrimSpace(cells.Eq(0).Text())
+		values[key] = strings.ToLower(strings.TrimSpace(cells.Eq(column).Text()))
+	})
+	return values
+}
+
+func TestGeonodeFetcher(t *testing.T) {
+	srv := serveFixtures(t, func(r *http.Request) string {
+		if r.URL.Path != "/api/proxy-list" || r.URL.Query().Get("limit") == "" {
+			return ""
+		}
+		if r.URL.Query().Get("page") == "1" {
+			return "geonode.json"
+		}
+		return "geonode_empty.json"
+	})
+
+	f := &fetcher.GeonodeFetcher{
+		BaseURL: srv.URL + "/api/proxy-list?limit=5",
+		Limit:   5,
+		Pages:   3,
+		Source:  "geonode",
+		Client:  srv.Client(),
+	}
+	checkProxies(t, collect(t, f), "geonode")
+}
+
+func TestProxyDBFetcher(t *testing.T) {
+	srv := serveFixtures(t, func(r *http.Request) string {
+		if r.URL.Query().Get("offset") == "0" {
+			return "proxydb.html"
+		}
+		return "proxydb_empty.html"
+	})
+
+	f := &fetcher.ProxyDBFetcher{
+		BaseURL: srv.URL + "/",
+		Source:  "proxydb",
+		Client:  srv.Client(),
+		LogPath: filepath.Join(t.TempDir(), "log.txt"),
+	}
+	proxies := collect(t, f)
+	checkProxies(t, proxies, "proxydb")
+
+	// Every row is kept, with the protocol its type names
+	types := fixtureColumn(t, "proxydb.html", "Type")
+	if len(proxies) != len(types) {
+		t.Errorf("got %d proxies, want one per row (%d)", len(proxies), len(types))
+	}
+	for _, p := range proxies {
+		if !strings.Contains(types[p.IP], string(p.Protocol)) {
+			t.Errorf("%v: protocol %s from type %q", p, p.Protocol, types[p.IP])
+		}
+	}
+}
+
+func TestProxyDBFetcherEmpty(t *testing.T) {
+	srv := serveFixtures(t, func(r *http.Request) string { return "proxydb_empty.html" })
+
+	f := &fetcher.ProxyDBFetcher{
+		BaseURL: srv.URL + "/",
+		Source:  "proxydb",
+		Client:  srv.Client(),
+		LogPath: filepath.Join(t.TempDir(), "log.txt"),
+	}
+	if proxies := collect(t, f); len(proxies) != 0 {
+		t.Fatalf("got %d proxies from an empty page, want 0", len(proxies))
+	}
+}
+
+func TestProxyDBFetcherRateLimited(t *testing.T) {
+	fixtures := serveFixtures(t, func(r *http.Request) string {
+		if r.URL.Query().Get("offset") == "0" {
+			return "proxydb.html"
+		}
+		return "proxydb_empty.html"
+	})
+	// Each page is refused a few times before it is served
+	var refused atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if refused.Add(1)%4 != 0 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fixtures.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	f := &fetcher.ProxyDBFetcher{
+		BaseURL:        srv.URL + "/",
+		Source:         "proxydb",
+		Client:         srv.Client(),
+		LogPath:        filepath.Join(t.TempDir(), "log.txt"),
+		RateLimitDelay: time.Millisecond,
+	}
+	proxies := collect(t, f)
+	if want := len(fixtureColumn(t, "proxydb.html", "Type")); len(proxies) != want {
+		t.Errorf("got %d proxies after 429s, want %d", len(proxies), want)
+	}
+	if n := refused.Load(); n != 8 {
+		t.Errorf("%d requests, want 3 refusals and a page, twice", n)
+	}
+}
+
+func TestRegexFetcherDefaultPattern(t *testing.T) {
+	srv := serveFixtures(t, func(r *http.Request) string { return "text.txt" })
+
+	f := &fetcher.RegexFetcher{URL: srv.URL + "/list.txt", Protocol: models.SOCKS4, Source: "regex", Client: srv.Client()}
+	proxies := collect(t, f)
+	checkProxies(t, proxies, "regex")
+
+	for _, p := range proxies {
+		if p.Protocol != models.SOCKS4 {
+			t.Errorf("%v: protocol = %s, want the configured socks4", p, p.Protocol)
+		}
+	}
+}
+
+func TestRegexFetcherPages(t *testing.T) {
+	pages := map[string]string{
+		"1": `<tr><td>10.0.0.1</td><td>1080</td><td>SOCKS v5</td></tr>
+		      <tr><td>10.0.0.2</td><td>8080</td><td>HTTPS</td></tr>`,
+		"2": `<tr><td>10.0.0.3</td><td>4145</td><td>socks4</td></tr>
+		      <tr><td>10.0.0.4</td><td>9999</td><td>carrier pigeon</td></tr>
+		      <tr><td>10.0.0.5</td><td>99999</td><td>socks4</td></tr>`,
+		"3": `<p>nothing here</p>`,
+		"4": `<tr><td>10.0.0.6</td><td>1080</td><td>socks5</td></tr>`,
+	}
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("p")
+		requested = append(requested, page+"/"+r.URL.Query().Get("skip"))
+		w.Write([]byte(pages[page]))
+	}))
+	defer srv.Close()
+
+	sources, err := fetcher.ParseSources([]byte(`
+sources:
+  - name: table
+    type: regex
+    url: ` + srv.URL + `/list?p={page}&skip={offset}
+    options:
+      pattern: '<td>(?P<ip>[\d.]+)</td><td>(?P<port>\d+)</td><td>(?P<protocol>[^<]+)</td>'
+      protocol_map: {"socks v5": socks5}
+      pages: 10
+      page_size: 50
+`))
+	if err != nil {
+		t.Fatalf("ParseSources: %v", err)
+	}
+	f, err := sources[0].Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	got := make(map[string]models.Protocol)
+	for _, p := range collect(t, f) {
+		got[p.IP] = p.Protocol
+	}
+	want := map[string]models.Protocol{
+		"10.0.0.1": models.SOCKS5,
+		"10.0.0.2": models.HTTP,
+		"10.0.0.3": models.SOCKS4,
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for ip, protocol := range want {
+		if got[ip] != protocol {
+			t.Errorf("%s: protocol = %q, want %q", ip, got[ip], protocol)
+		}
+	}
+
+	// Paging stops at the first page without proxies
+	wantRequests := []string{"1/0", "2/50", "3/100"}
+	if len(requested) != len(wantRequests) {
+		t.Fatalf("requested pages %v, want %v", requested, wantRequests)
+	}
+	for i := range wantRequests {
+		if requested[i] != wantRequests[i] {
+			t.Errorf("request %d = %s, want %s", i, requested[i], wantRequests[i])
+		}
+	}
+}
+
+func TestRegexSourceValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		options fetcher.SourceOptions
+	}{
+		{"bad syntax", fetcher.SourceOptions{Pattern: `(?P<ip>[`}},
+		{"missing port group", fetcher.SourceOptions{Pattern: `(?P<ip>[\d.]+)`}},
+		{"bad protocol map", fetcher.SourceOptions{ProtocolMap: map[string]string{"x": "gopher"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fetcher.SourceConfig{Type: fetcher.SourceRegex, URL: "https://example.com/?page={page}", Options: tt.options}
+			if err := c.Validate(); err == nil {
+				t.Error("Validate succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestTableFetcherColumns(t *testing.T) {
+	srv := serveFixtures(t, func(r *http.Request) string { return "free-proxy-list.html" })
+
+	f := &fetcher.TableFetcher{
+		URL:    srv.URL + "/ru/",
+		Rows:   ".fpl-list table tbody tr",
+		IP:     fetcher.TableField{Column: "IP Address"},
+		Port:   fetcher.TableField{Column: "port"},
+		Source: "table",
+		Client: srv.Client(),
+	}
+	checkProxies(t, collect(t, f), "table")
+}
+
+func TestTableFetcherNextLink(t *testing.T) {
+	pages := map[string]string{
+		"/list": `<table><tr><th>Host</th><th>Port</th><th>Kind</th></tr>
+			<tr><td> 10.0.0.1 </td><td><a data-port="1080">hidden</a></td><td>SOCKS v5</td></tr>
+			<tr><td>10.0.0.2</td><td><a data-port="8080">hidden</a></td><td>HTTP</td></tr>
+			<tr><td colspan="3">advertisement</td></tr>
+		</table><a class="next" href="page2">next</a>`,
+		"/page2": `<table><tr><th>Host</th><th>Port</th><th>Kind</th></tr>
+			<tr><td>10.0.0.3</td><td><a data-port="4145">hidden</a></td><td>Socks4</td></tr>
+			<tr><td>10.0.0.4</td><td><a data-port="1">hidden</a></td><td>gopher</td></tr>
+		</table><a class="next" href="/list">back to start</a>`,
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(pages[r.URL.Path]))
+	}))
+	defer srv.Close()
+
+	sources, err := fetcher.ParseSources([]byte(`
+sources:
+  - name: table
+    type: table
+    url: ` + srv.URL + `/list
+    options:
+      rows: "table tr"
+      next: "a.next"
+      fields:
+        ip: {column: host}
+        port: {selector: "td:nth-child(2) a", attr: data-port}
+        protocol: {column: kind, transforms: [lower], map: {"socks v5": socks5}}
+`))
+	if err != nil {
+		t.Fatalf("ParseSources: %v", err)
+	}
+	f, err := sources[0].Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, p := range collect(t, f) {
+		got[p.IP] = string(p.Protocol) + " " + p.Port
+	}
+	want := map[string]string{
+		"10.0.0.1": "socks5 1080",
+		"10.0.0.2": "http 8080",
+		"10.0.0.3": "socks4 4145",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for ip, w := range want {
+		if got[ip] != w {
+			t.Errorf("%s = %q, want %q", ip, got[ip], w)
+		}
+	}
+}
+
+func TestTableSourceValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		options fetcher.SourceOptions
+	}{
+		{"no rows", fetcher.SourceOptions{Fields: map[string]fetcher.TableField{"ip": {Column: "ip"}, "port": {Column: "port"}}}},
+		{"no port", fetcher.SourceOptions{Rows: "tr", Fields: map[string]fetcher.TableField{"ip": {Column: "ip"}}}},
+		{"unknown field", fetcher.SourceOptions{Rows: "tr", Fields: map[string]fetcher.TableField{"ip": {Column: "ip"}, "port": {Column: "port"}, "country": {Column: "cc"}}}},
+		{"bad selector", fetcher.SourceOptions{Rows: "tr[", Fields: map[string]fetcher.TableField{"ip": {Column: "ip"}, "port": {Column: "port"}}}},
+		{"bad transform", fetcher.SourceOptions{Rows: "tr", Fields: map[string]fetcher.TableField{"ip": {Column: "ip", Transforms: []string{"rot13"}}, "port": {Column: "port"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fetcher.SourceConfig{Type: fetcher.SourceTable, URL: "https://example.com/", Options: tt.options}
+			if err := c.Validate(); err == nil {
+				t.Error("Validate succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestJSONFetcherCursor(t *testing.T) {
+	pages := map[string]string{
+		"": `{"result": {"items": [
+			{"addr": {"host": "10.0.0.1", "port": 1080}, "types": ["SOCKS5", "socks4"], "geo": {"cc": "DE"}, "level": "Elite"},
+			{"addr": {"host": "10.0.0.2", "port": "8080"}, "types": ["https", "http"]},
+			{"addr": {"host": "10.0.0.3", "port": 3128}, "types": ["gopher"]}
+		]}, "meta": {"next": "c2"}}`,
+		"c2": `{"result": {"items": [
+			{"addr": {"host": "10.0.0.4", "port": 4145}, "types": "socks4"}
+		]}, "meta": {"next": "/api?cursor=c3&limit=2"}}`,
+		"c3": `{"result": {"items": []}, "meta": {"next": "c4"}}`,
+	}
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RawQuery)
+		w.Write([]byte(pages[r.URL.Query().Get("cursor")]))
+	}))
+	defer srv.Close()
+
+	sources, err := fetcher.ParseSources([]byte(`
+sources:
+  - name: api
+    type: json
+    url: ` + srv.URL + `/api
+    options:
+      paths: {items: result.items, ip: addr.host, port: addr.port, protocol: types, country: geo.cc, anonymity: level}
+      pagination: cursor
+      next: $.meta.next
+      limit: 2
+`))
+	if err != nil {
+		t.Fatalf("ParseSources: %v", err)
+	}
+	f, err := sources[0].Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	got := make(map[string]bool)
+	for _, p := range collect(t, f) {
+		got[fmt.Sprintf("%s://%s %s %s", p.Protocol, p.Address(), p.Country, p.Anonymity)] = true
+	}
+	want := []string{
+		"socks5://10.0.0.1:1080 DE elite",
+		"socks4://10.0.0.1:1080 DE elite",
+		"http://10.0.0.2:8080  ",
+		"socks4://10.0.0.4:4145  ",
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, w := range want {
+		if !got[w] {
+			t.Errorf("missing %q", w)
+		}
+	}
+
+	wantRequests := []string{"limit=2", "cursor=c2&limit=2", "cursor=c3&limit=2"}
+	if fmt.Sprint(requests) != fmt.Sprint(wantRequests) {
+		t.Errorf("requests = %q, want %q", requests, wantRequests)
+	}
+}
+
+func TestJSONFetcherOffset(t *testing.T) {
+	var offsets []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset := r.URL.Query().Get("skip")
+		offsets = append(offsets, offset)
+		switch offset {
+		case "0":
+			w.Write([]byte(`[{"proxy": "10.0.0.1:8080"}, {"proxy": "10.0.0.2:8080"}]`))
+		case "2":
+			w.Write([]byte(`[{"proxy": "10.0.0.3:8080"}]`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer srv.Close()
+
+	f := &fetcher.JSONFetcher{
+		URL:             srv.URL + "/list?format=json",
+		IP:              "proxy",
+		DefaultProtocol: models.SOCKS5,
+		Pagination:      fetcher.PaginateOffset,
+		PageParam:       "skip",
+		Limit:           2,
+		LimitParam:      "count",
+		Source:          "offset",
+	}
+	proxies := collect(t, f)
+	checkProxies(t, proxies, "offset")
+	if len(proxies) != 3 || proxies[0].Protocol != models.SOCKS5 {
+		t.Errorf("got %v, want 3 socks5 proxies", proxies)
+	}
+	if fmt.Sprint(offsets) != "[0 2 4]" {
+		t.Errorf("offsets = %v, want [0 2 4]", offsets)
+	}
+}
+
+func TestJSONSourceValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		options fetcher.SourceOptions
+	}{
+		{"no ip", fetcher.SourceOptions{Paths: map[string]string{"port": "port"}}},
+		{"unknown path", fetcher.SourceOptions{Paths: map[string]string{"ip": "ip", "user": "login"}}},
+		{"unknown pagination", fetcher.SourceOptions{Paths: map[string]string{"ip": "ip"}, Pagination: "scroll"}},
+		{"offset without limit", fetcher.SourceOptions{Paths: map[string]string{"ip": "ip"}, Pagination: "offset"}},
+		{"cursor without next", fetcher.SourceOptions{Paths: map[string]string{"ip": "ip"}, Pagination: "cursor"}},
+		{"bad protocol_map", fetcher.SourceOptions{Paths: map[string]string{"ip": "ip"}, ProtocolMap: map[string]string{"v5": "socks6"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fetcher.SourceConfig{Type: fetcher.SourceJSON, URL: "https://example.com/", Options: tt.options}
+			if err := c.Validate(); err == nil {
+				t.Error("Validate succeeded, want an error")
+			}
+		})
+	}
+}
@@ -1,88 +1,32 @@
 package fetcher
 
 import (
-	"fmt"
+	"context"
 	"net/http"
-	"strings"
-	"time"
 
 	"ProxyParserGO/pkg/models"
-
-	"github.com/PuerkitoBio/goquery"
 )
 
+// HTMLFetcher scrapes the free-proxy-list.net table.
 type HTMLFetcher struct {
 	URL    string
 	Source string
+	Client *http.Client // nil uses a default client
 }
 
-func (f *HTMLFetcher) Fetch(logger Logger, onProxies ProxyCallback) error {
-	if logger != nil {
-		logger(fmt.Sprintf("Fetching HTML from %s...", f.Source))
-	}
-
-	client := http.Client{
-		Timeout: 30 * time.Second,
-	}
-
-	resp, err := client.Get(f.URL)
-	if err != nil {
-		if logger != nil {
-			logger(fmt.Sprintf("Error fetching %s: %v", f.Source, err))
-		}
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		if logger != nil {
-			logger(fmt.Sprintf("Bad status %s for %s", resp.Status, f.Source))
-		}
-		return fmt.Errorf("bad status: %s", resp.Status)
-	}
-
-	doc, err := goquery.NewDocumentFromReader(resp.Body)
-	if err != nil {
-		if logger != nil {
-			logger(fmt.Sprintf("Error parsing HTML for %s: %v", f.Source, err))
-		}
-		return err
-	}
-
-	var proxies []models.Proxy
-	count := 0
-	doc.Find(".fpl-list table tbody tr").Each(func(i int, s *goquery.Selection) {
-		tds := s.Find("td")
-		if tds.Length() >= 7 {
-			ip := strings.TrimSpace(tds.Eq(0).Text())
-			port := strings.TrimSpace(tds.Eq(1).Text())
-			https := strings.TrimSpace(strings.ToLower(tds.Eq(6).Text()))
-
-			if ip == "" {
-				return
-			}
-
-			protocol := models.HTTP
-			if https == "yes" {
-				protocol = models.HTTP
-			}
-
-			proxies = append(proxies, models.Proxy{
-				IP:       ip,
-				Port:     port,
-				Protocol: protocol,
-				Source:   f.Source,
-			})
-			count++
-		}
-	})
-
-	if len(proxies) > 0 && onProxies != nil {
-		onProxies(proxies)
-	}
+func (f *HTMLFetcher) Fetch(ctx context.Context, logger Logger, onProxies ProxyCallback) error {
+	return f.table().Fetch(ctx, logger, onProxies)
+}
 
-	if logger != nil {
-		logger(fmt.Sprintf("Fetched %d proxies from %s", count, f.Source))
+func (f *HTMLFetcher) table() *TableFetcher {
+	return &TableFetcher{
+		URL:             f.URL,
+		Rows:            ".fpl-list table tbody tr",
+		IP:              TableField{Selector: "td:nth-child(1)"},
+		Port:            TableField{Selector: "td:nth-child(2)"},
+		HTTPS:           TableField{Selector: "td:nth-child(7)"},
+		DefaultProtocol: models.HTTP,
+		Source:          f.Source,
+		Client:          f.Client,
 	}
-	return nil
 }
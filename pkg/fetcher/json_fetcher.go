@@ -0,0 +1,377 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"ProxyParserGO/pkg/models"
+)
+
+// Pagination strategies for JSONFetcher.
+const (
+	PaginateNone   = "none"   // a single request
+	PaginatePage   = "page"   // page number query parameter
+	PaginateOffset = "offset" // offset query parameter advancing by Limit
+	PaginateCursor = "cursor" // cursor or next-page URL read from each response
+)
+
+// maxJSONPages bounds paging when no page limit is configured.
+const maxJSONPages = 1000
+
+// JSONFetcher reads proxies from a JSON API. Fields are located with
+// paths: dot-separated keys with [n] for array elements, such as
+// "data.proxies" or "result[0].ip". A leading "$." is ignored.
+//
+// Every pagination strategy stops at the first page without items, at the
+// page limit, or when a cursor response has no next cursor.
+type JSONFetcher struct {
+	URL string
+
+	// Items is the path to the array of proxy objects, empty when the
+	// response itself is the array. The other paths are relative to each
+	// item. Protocol may point at a string or an array of strings, giving
+	// one proxy per protocol.
+	Items     string
+	IP        string
+	Port      string
+	Protocol  string
+	Country   string
+	Anonymity string
+
+	// DefaultProtocol is used when Protocol is unset or empty. ProtocolMap
+	// translates protocol values, compared case-insensitively; unmapped
+	// values are parsed as protocol names and unknown ones are skipped.
+	DefaultProtocol models.Protocol
+	ProtocolMap     map[string]models.Protocol
+
+	Pagination string // PaginateNone (default), PaginatePage, PaginateOffset or PaginateCursor
+	PageParam  string // query parameter carrying the page, offset or cursor
+	FirstPage  int    // first page number, default 1
+	Limit      int    // page size, sent as LimitParam and used as the offset step
+	LimitParam string // default "limit"
+	Next       string // cursor mode: path to the next cursor or next-page URL
+	Pages      int    // page limit, 0 means until an empty page
+
+	Source string
+	Client *http.Client // nil uses a default client
+}
+
+// Validate checks the pagination settings and required paths.
+func (f *JSONFetcher) Validate() error {
+	if f.IP == "" {
+		return errors.New("ip path is required")
+	}
+	switch f.Pagination {
+	case "", PaginateNone, PaginatePage:
+	case PaginateOffset:
+		if f.Limit <= 0 {
+			return errors.New("offset pagination requires a limit")
+		}
+	case PaginateCursor:
+		if f.Next == "" {
+			return errors.New("cursor pagination requires a next path")
+		}
+	default:
+		return fmt.Errorf("unknown pagination: %q", f.Pagination)
+	}
+	return nil
+}
+
+func (f *JSONFetcher) Fetch(ctx context.Context, logger Logger, onProxies ProxyCallback) error {
+	log := func(msg string) {
+		if logger != nil {
+			logger(fmt.Sprintf("[%s] %s", f.Source, msg))
+		}
+	}
+
+	if err := f.Validate(); err != nil {
+		log(fmt.Sprintf("Invalid JSON config: %v", err))
+		return err
+	}
+
+	pages := f.Pages
+	switch {
+	case f.Pagination == "" || f.Pagination == PaginateNone:
+		pages = 1
+	case pages < 1:
+		pages = maxJSONPages
+	}
+
+	client := httpClient(f.Client)
+	pageURL, err := f.pageURL(0, "")
+	if err != nil {
+		return err
+	}
+	total := 0
+
+	for i := 0; i < pages && ctx.Err() == nil; i++ {
+		var doc any
+		if err := fetchJSON(ctx, client, pageURL, &doc); err != nil {
+			if ctx.Err() == nil {
+				log(fmt.Sprintf("Error fetching %s: %v", pageURL, err))
+			}
+			break
+		}
+
+		proxies, skipped, err := f.extract(doc)
+		if err != nil {
+			log(fmt.Sprintf("Unexpected response from %s: %v", pageURL, err))
+			break
+		}
+		if skipped > 0 {
+			log(fmt.Sprintf("Skipped %d items with an unknown protocol or invalid address", skipped))
+		}
+		if len(proxies) == 0 {
+			break
+		}
+		if onProxies != nil {
+			onProxies(proxies)
+		}
+		total += len(proxies)
+
+		var cursor string
+		if f.Pagination == PaginateCursor {
+			v, ok := lookupPath(doc, f.Next)
+			cursor = scalarString(v)
+			if !ok || cursor == "" {
+				break
+			}
+		}
+		if pageURL, err = f.pageURL(i+1, cursor); err != nil {
+			return err
+		}
+
+		if pages > 1 {
+			log(fmt.Sprintf("Fetched %d proxies from page %d", len(proxies), i+1))
+			if i < pages-1 && sleepContext(ctx, 500*time.Millisecond) != nil {
+				break
+			}
+		}
+	}
+
+	log(fmt.Sprintf("Fetched %d proxies", total))
+	return ctx.Err()
+}
+
+// pageURL returns the URL of page i, counting from 0. cursor is the value
+// read from the previous page in cursor mode.
+func (f *JSONFetcher) pageURL(i int, cursor string) (string, error) {
+	u, err := url.Parse(f.URL)
+	if err != nil {
+		return "", err
+	}
+
+	// A full or relative URL as the cursor is a next-page link
+	if cursor != "" && (strings.Contains(cursor, "://") || strings.HasPrefix(cursor, "/")) {
+		ref, err := url.Parse(cursor)
+		if err != nil {
+			return "", err
+		}
+		return u.ResolveReference(ref).String(), nil
+	}
+
+	q := u.Query()
+	if f.Limit > 0 && f.Pagination != "" && f.Pagination != PaginateNone {
+		q.Set(defaultString(f.LimitParam, "limit"), strconv.Itoa(f.Limit))
+	}
+	switch f.Pagination {
+	case PaginatePage:
+		first := f.FirstPage
+		if first == 0 {
+			first = 1
+		}
+		q.Set(defaultString(f.PageParam, "page"), strconv.Itoa(first+i))
+	case PaginateOffset:
+		q.Set(defaultString(f.PageParam, "offset"), strconv.Itoa(i*f.Limit))
+	case PaginateCursor:
+		if cursor == "" {
+			break
+		}
+		q.Set(defaultString(f.PageParam, "cursor"), cursor)
+	default:
+		return u.String(), nil
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// extract returns the proxies in a decoded response and how many items
+// were rejected.
+func (f *JSONFetcher) extract(doc any) (proxies []models.Proxy, skipped int, err error) {
+	v, ok := lookupPath(doc, f.Items)
+	if !ok || v == nil {
+		return nil, 0, nil
+	}
+	items, ok := v.([]any)
+	if !ok {
+		return nil, 0, fmt.Errorf("%q is not an array", f.Items)
+	}
+
+	field := func(item any, path string) string {
+		if path == "" {
+			return ""
+		}
+		v, _ := lookupPath(item, path)
+		return strings.TrimSpace(scalarString(v))
+	}
+
+	for _, item := range items {
+		ip, port := field(item, f.IP), field(item, f.Port)
+		if f.Port == "" {
+			// "ip:port" in a single field
+			if host, p, err := net.SplitHostPort(ip); err == nil {
+				ip, port = host, p
+			}
+		}
+		if !validAddress(ip, port) {
+			skipped++
+			continue
+		}
+
+		var names []string
+		if f.Protocol != "" {
+			v, _ := lookupPath(item, f.Protocol)
+			if list, ok := v.([]any); ok {
+				for _, name := range list {
+					names = append(names, scalarString(name))
+				}
+			} else if name := scalarString(v); name != "" {
+				names = append(names, name)
+			}
+		}
+
+		protocols := f.protocols(names)
+		if len(protocols) == 0 {
+			skipped++
+			continue
+		}
+
+		anonymity, _ := models.ParseAnonymity(field(item, f.Anonymity))
+		for _, protocol := range protocols {
+			proxies = append(proxies, models.Proxy{
+				IP:        ip,
+				Port:      port,
+				Protocol:  protocol,
+				Source:    f.Source,
+				Country:   field(item, f.Country),
+				Anonymity: anonymity,
+			})
+		}
+	}
+	return proxies, skipped, nil
+}
+
+// protocols resolves protocol names, dropping unknown ones and duplicates
+// such as "http" and "https".
+func (f *JSONFetcher) protocols(names []string) []models.Protocol {
+	if len(names) == 0 {
+		if f.DefaultProtocol == "" {
+			return []models.Protocol{models.HTTP}
+		}
+		return []models.Protocol{f.DefaultProtocol}
+	}
+
+	var protocols []models.Protocol
+	seen := make(map[models.Protocol]bool)
+	for _, name := range names {
+		protocol, ok := mapProtocol(f.ProtocolMap, strings.TrimSpace(name))
+		if !ok || seen[protocol] {
+			continue
+		}
+		seen[protocol] = true
+		protocols = append(protocols, protocol)
+	}
+	return protocols
+}
+
+// mapProtocol translates a protocol name through m, falling back to
+// models.ParseProtocol.
+func mapProtocol(m map[string]models.Protocol, name string) (models.Protocol, bool) {
+	for from, protocol := range m {
+		if strings.EqualFold(from, name) {
+			return protocol, true
+		}
+	}
+	protocol, err := models.ParseProtocol(name)
+	return protocol, err == nil
+}
+
+// lookupPath follows a path such as "data.items[0].ip" through decoded
+// JSON. An empty path returns v itself.
+func lookupPath(v any, path string) (any, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return v, true
+	}
+
+	for _, part := range strings.Split(path, ".") {
+		key := part
+		var indexes []int
+		if i := strings.Index(part, "["); i != -1 {
+			key = part[:i]
+			for _, idx := range strings.Split(strings.TrimSuffix(part[i+1:], "]"), "][") {
+				n, err := strconv.Atoi(idx)
+				if err != nil {
+					return nil, false
+				}
+				indexes = append(indexes, n)
+			}
+		}
+
+		if key != "" {
+			obj, ok := v.(map[string]any)
+			if !ok {
+				return nil, false
+			}
+			if v, ok = obj[key]; !ok {
+				return nil, false
+			}
+		}
+		for _, n := range indexes {
+			list, ok := v.([]any)
+			if !ok || n < 0 || n >= len(list) {
+				return nil, false
+			}
+			v = list[n]
+		}
+	}
+	return v, true
+}
+
+// scalarString formats a decoded JSON string or number, and returns "" for
+// anything else.
+func scalarString(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+func defaultString(s, fallback string) string {
+	if s == "" {
+		return fallback
+	}
+	return s
+}
+
+// fetchJSON downloads url and decodes the response into v.
+func fetchJSON(ctx context.Context, client *http.Client, url string, v any) error {
+	body, err := fetchBody(ctx, client, url)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
@@ -1,25 +1,39 @@
 package fetcher
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
-	"ProxyParserGO/pkg/models"
-
 	"github.com/PuerkitoBio/goquery"
 )
 
 type ProxyDBFetcher struct {
 	BaseURL string
 	Source  string
+	Client  *http.Client // nil uses a default client
+	LogPath string       // file the fetch log is appended to, log.txt when empty
+
+	// RateLimitDelay is the wait after a page is refused with 429, growing
+	// by as much with each refusal in a row up to maxRateLimited of them,
+	// 5s when zero. A longer Retry-After is waited out instead. The site
+	// throttles well past what the client's own retries cover.
+	RateLimitDelay time.Duration
 }
 
-func (f *ProxyDBFetcher) Fetch(logger Logger, onProxies ProxyCallback) error {
+// maxRateLimited is how many 429s in a row the fetch waits out.
+const maxRateLimited = 10
+
+func (f *ProxyDBFetcher) Fetch(ctx context.Context, logger Logger, onProxies ProxyCallback) error {
 	// Open log file for persistent logging
-	logFile, err := os.OpenFile("log.txt", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	logPath := f.LogPath
+	if logPath == "" {
+		logPath = "log.txt"
+	}
+	logFile, err := os.OpenFile(logPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil && logger != nil {
 		logger(fmt.Sprintf("Error opening log file: %v", err))
 	} else if logFile != nil {
@@ -39,34 +53,50 @@ func (f *ProxyDBFetcher) Fetch(logger Logger, onProxies ProxyCallback) error {
 		}
 	}
 
-	client := http.Client{Timeout: 30 * time.Second}
+	client := httpClient(f.Client)
 	offset := 0
 	step := 30
 	retries := 0
 	totalFetched := 0
+	rateDelay := f.RateLimitDelay
+	if rateDelay <= 0 {
+		rateDelay = 5 * time.Second
+	}
 
 	log("Starting fetch...")
 
-	for {
+	for ctx.Err() == nil {
 		url := fmt.Sprintf("%s?offset=%d", f.BaseURL, offset)
 		log(fmt.Sprintf("Fetching offset %d...", offset))
 
-		resp, err := client.Get(url)
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := client.Do(req)
 		if err != nil {
-			log(fmt.Sprintf("Error fetching %s: %v", url, err))
+			if ctx.Err() == nil {
+				log(fmt.Sprintf("Error fetching %s: %v", url, err))
+			}
 			break
 		}
 
 		if resp.StatusCode == http.StatusTooManyRequests {
 			resp.Body.Close()
 			retries++
-			if retries > 10 {
+			if retries > maxRateLimited {
 				log("Max retries reached for 429. Stopping.")
 				break
 			}
-			delay := time.Duration(retries*5) * time.Second
+			delay := time.Duration(retries) * rateDelay
+			if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok && d > delay {
+				delay = d
+			}
 			log(fmt.Sprintf("Rate limited (429). Sleeping %s...", delay))
-			time.Sleep(delay)
+			if sleepContext(ctx, delay) != nil {
+				break
+			}
 			continue
 		}
 
@@ -97,46 +127,14 @@ func (f *ProxyDBFetcher) Fetch(logger Logger, onProxies ProxyCallback) error {
 			break
 		}
 
-		rows := doc.Find("div.table-responsive table tbody tr")
-		if rows.Length() == 0 {
+		table := f.table()
+		if doc.Find(table.Rows).Length() == 0 {
 			log("No rows found. Stopping.")
 			break
 		}
 
-		count := 0
-		var pageProxies []models.Proxy
-		rows.Each(func(i int, s *goquery.Selection) {
-			ipLink := s.Find("td:nth-child(1) a")
-			ip := strings.TrimSpace(ipLink.Text())
-
-			portLink := s.Find("td:nth-child(2) a")
-			port := strings.TrimSpace(portLink.Text())
-
-			protocolStr := strings.TrimSpace(s.Find("td:nth-child(3)").Text())
-
-			if ip == "" || port == "" {
-				return
-			}
-
-			var protocol models.Protocol
-			protocolStrLower := strings.ToLower(protocolStr)
-			if strings.Contains(protocolStrLower, "socks5") {
-				protocol = models.SOCKS5
-			} else if strings.Contains(protocolStrLower, "socks4") {
-				protocol = models.SOCKS4
-			} else {
-				protocol = models.HTTP
-			}
-
-			p := models.Proxy{
-				IP:       ip,
-				Port:     port,
-				Protocol: protocol,
-				Source:   f.Source,
-			}
-			pageProxies = append(pageProxies, p)
-			count++
-		})
+		pageProxies, _ := table.extract(doc)
+		count := len(pageProxies)
 
 		if len(pageProxies) > 0 && onProxies != nil {
 			onProxies(pageProxies)
@@ -150,9 +148,28 @@ func (f *ProxyDBFetcher) Fetch(logger Logger, onProxies ProxyCallback) error {
 		}
 
 		offset += step
-		time.Sleep(500 * time.Millisecond)
+		if sleepContext(ctx, 500*time.Millisecond) != nil {
+			break
+		}
+	}
+
+	if ctx.Err() != nil {
+		log(fmt.Sprintf("Cancelled. Total: %d", totalFetched))
+		return ctx.Err()
 	}
 
 	log(fmt.Sprintf("Finished fetching. Total: %d", totalFetched))
 	return nil
 }
+
+// table describes the listing. The type column reads "HTTPS (elite)",
+// "SOCKS4/5" and the like, hence the protocol transform.
+func (f *ProxyDBFetcher) table() *TableFetcher {
+	return &TableFetcher{
+		Rows:     "div.table-responsive table tbody tr",
+		IP:       TableField{Selector: "td:nth-child(1) a"},
+		Port:     TableField{Selector: "td:nth-child(2) a"},
+		Protocol: TableField{Selector: "td:nth-child(3)", Transforms: []string{"protocol"}},
+		Source:   f.Source,
+	}
+}
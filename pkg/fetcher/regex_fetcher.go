@@ -0,0 +1,193 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"ProxyParserGO/pkg/models"
+)
+
+// DefaultPattern finds ip:port pairs anywhere in text or HTML.
+const DefaultPattern = `(?P<ip>\b(?:\d{1,3}\.){3}\d{1,3})\s*:\s*(?P<port>\d{1,5})\b`
+
+// RegexFetcher extracts proxies from any page with a regular expression.
+// The pattern must have "ip" and "port" named groups and may also have
+// "protocol", "user" and "pass" groups.
+//
+// URL may contain {page} and {offset} placeholders to fetch several pages:
+// {page} counts up from FirstPage and {offset} advances by PageSize. Paging
+// stops early at the first page without proxies.
+type RegexFetcher struct {
+	URL     string
+	Pattern string // empty uses DefaultPattern
+
+	// Protocol is used when the pattern has no protocol group or captures
+	// an empty one. ProtocolMap translates captured protocol text, compared
+	// case-insensitively; unmapped values are parsed as protocol names and
+	// matches with unknown protocols are skipped.
+	Protocol    models.Protocol
+	ProtocolMap map[string]models.Protocol
+
+	Pages     int // pages to fetch when URL is a template, default 1
+	FirstPage int // value of {page} on the first page, default 1
+	PageSize  int // {offset} step between pages
+
+	Source string
+	Client *http.Client // nil uses a default client
+}
+
+// compilePattern compiles a RegexFetcher pattern and checks that it has the
+// required groups.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		pattern = DefaultPattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range []string{"ip", "port"} {
+		if re.SubexpIndex(group) == -1 {
+			return nil, fmt.Errorf("pattern has no %q group", group)
+		}
+	}
+	return re, nil
+}
+
+func (f *RegexFetcher) Fetch(ctx context.Context, logger Logger, onProxies ProxyCallback) error {
+	log := func(msg string) {
+		if logger != nil {
+			logger(fmt.Sprintf("[%s] %s", f.Source, msg))
+		}
+	}
+
+	re, err := compilePattern(f.Pattern)
+	if err != nil {
+		log(fmt.Sprintf("Invalid pattern: %v", err))
+		return err
+	}
+
+	pages := f.Pages
+	if pages < 1 || !isTemplate(f.URL) {
+		pages = 1
+	}
+	firstPage := f.FirstPage
+	if firstPage == 0 {
+		firstPage = 1
+	}
+
+	client := httpClient(f.Client)
+	total := 0
+	for i := 0; i < pages; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+
+		url := expandURL(f.URL, firstPage+i, i*f.PageSize)
+		body, err := fetchBody(ctx, client, url)
+		if err != nil {
+			if ctx.Err() == nil {
+				log(fmt.Sprintf("Error fetching %s: %v", url, err))
+			}
+			break
+		}
+
+		proxies, skipped := f.extract(re, body)
+		if skipped > 0 {
+			log(fmt.Sprintf("Skipped %d matches with an unknown protocol or invalid address", skipped))
+		}
+		if len(proxies) == 0 {
+			break
+		}
+		if onProxies != nil {
+			onProxies(proxies)
+		}
+		total += len(proxies)
+
+		if pages > 1 {
+			log(fmt.Sprintf("Fetched %d proxies from page %d", len(proxies), firstPage+i))
+			if i < pages-1 && sleepContext(ctx, 500*time.Millisecond) != nil {
+				break
+			}
+		}
+	}
+
+	log(fmt.Sprintf("Fetched %d proxies", total))
+	return ctx.Err()
+}
+
+// extract returns the proxies matched in body and how many matches were
+// rejected.
+func (f *RegexFetcher) extract(re *regexp.Regexp, body []byte) (proxies []models.Proxy, skipped int) {
+	group := func(m [][]byte, name string) string {
+		if i := re.SubexpIndex(name); i != -1 {
+			return strings.TrimSpace(string(m[i]))
+		}
+		return ""
+	}
+
+	seen := make(map[string]bool)
+	for _, m := range re.FindAllSubmatch(body, -1) {
+		ip, port := group(m, "ip"), group(m, "port")
+		if !validAddress(ip, port) {
+			skipped++
+			continue
+		}
+
+		protocol, ok := f.protocol(group(m, "protocol"))
+		if !ok {
+			skipped++
+			continue
+		}
+
+		p := models.Proxy{
+			IP:       ip,
+			Port:     port,
+			Protocol: protocol,
+			Source:   f.Source,
+			Username: group(m, "user"),
+			Password: group(m, "pass"),
+		}
+		key := string(p.Protocol) + "://" + p.Address()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		proxies = append(proxies, p)
+	}
+	return proxies, skipped
+}
+
+// protocol resolves captured protocol text.
+func (f *RegexFetcher) protocol(captured string) (models.Protocol, bool) {
+	if captured == "" {
+		if f.Protocol == "" {
+			return models.HTTP, true
+		}
+		return f.Protocol, true
+	}
+	return mapProtocol(f.ProtocolMap, captured)
+}
+
+func isTemplate(url string) bool {
+	return strings.Contains(url, "{page}") || strings.Contains(url, "{offset}")
+}
+
+func expandURL(template string, page, offset int) string {
+	return strings.NewReplacer(
+		"{page}", strconv.Itoa(page),
+		"{offset}", strconv.Itoa(offset),
+	).Replace(template)
+}
+
+// validAddress reports whether ip and port form a usable proxy address.
+func validAddress(ip, port string) bool {
+	n, err := strconv.Atoi(port)
+	return net.ParseIP(ip) != nil && err == nil && n > 0 && n <= 65535
+}
@@ -0,0 +1,393 @@
+package fetcher
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"ProxyParserGO/pkg/models"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed default_sources.yaml
+var defaultSources []byte
+
+// Source types understood by SourceConfig.Build.
+const (
+	SourceText    = "text"
+	SourceHTML    = "html"
+	SourceGeonode = "geonode"
+	SourceProxyDB = "proxydb"
+	SourceRegex   = "regex"
+	SourceTable   = "table"
+	SourceJSON    = "json"
+)
+
+// SourceOptions holds per-type settings. Fields that do not apply to a
+// source's type are ignored.
+type SourceOptions struct {
+	Limit int `yaml:"limit" json:"limit"`
+	Pages int `yaml:"pages" json:"pages"`
+
+	// URL templates of regex and table sources, and page numbers of json
+	// sources
+	FirstPage int `yaml:"first_page" json:"first_page"`
+	PageSize  int `yaml:"page_size" json:"page_size"`
+
+	// Regex sources, and protocol translation of regex and json sources
+	Pattern     string            `yaml:"pattern" json:"pattern"`
+	ProtocolMap map[string]string `yaml:"protocol_map" json:"protocol_map"`
+
+	// Table sources. Fields maps ip, port, protocol, user, pass and https
+	// to where they are found in each row.
+	Rows   string                `yaml:"rows" json:"rows"`
+	Fields map[string]TableField `yaml:"fields" json:"fields"`
+
+	// Next is the next-page link selector of table sources and the path to
+	// the next cursor of json sources.
+	Next string `yaml:"next" json:"next"`
+
+	// JSON sources. Paths maps items, ip, port, protocol, country and
+	// anonymity to where they are found in the response.
+	Paths      map[string]string `yaml:"paths" json:"paths"`
+	Pagination string            `yaml:"pagination" json:"pagination"`
+	PageParam  string            `yaml:"page_param" json:"page_param"`
+	LimitParam string            `yaml:"limit_param" json:"limit_param"`
+}
+
+// SourceConfig describes a single proxy source in a sources file.
+type SourceConfig struct {
+	Name     string        `yaml:"name" json:"name"`
+	Type     string        `yaml:"type" json:"type"`
+	URL      string        `yaml:"url" json:"url"`
+	Protocol string        `yaml:"protocol" json:"protocol"`
+	Enabled  *bool         `yaml:"enabled" json:"enabled"`
+	Refresh  string        `yaml:"refresh" json:"refresh"` // minimum time between downloads, such as "6h"
+	Proxy    string        `yaml:"proxy" json:"proxy"`     // proxy URL, RouteDirect or RoutePool
+	Options  SourceOptions `yaml:"options" json:"options"`
+}
+
+type sourcesFile struct {
+	Sources []SourceConfig `yaml:"sources" json:"sources"`
+}
+
+// IsEnabled reports whether the source should be fetched. Sources are
+// enabled unless explicitly disabled.
+func (c SourceConfig) IsEnabled() bool {
+	return c.Enabled == nil || *c.Enabled
+}
+
+// Validate checks that the entry has everything its type needs.
+func (c SourceConfig) Validate() error {
+	if c.URL == "" {
+		return errors.New("url is required")
+	}
+	u, err := url.Parse(expandURL(c.URL, 1, 0))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid url: %q", c.URL)
+	}
+
+	switch c.Type {
+	case SourceText:
+		if c.Protocol == "" {
+			return errors.New("protocol is required for text sources")
+		}
+	case SourceRegex:
+		if _, err := compilePattern(c.Options.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern: %w", err)
+		}
+		if _, err := c.protocolMap(); err != nil {
+			return err
+		}
+	case SourceJSON:
+		if _, err := c.json(""); err != nil {
+			return err
+		}
+	case SourceTable:
+		if _, err := c.table(""); err != nil {
+			return err
+		}
+	case SourceHTML, SourceGeonode, SourceProxyDB:
+	case "":
+		return errors.New("type is required")
+	default:
+		return fmt.Errorf("unknown type: %q", c.Type)
+	}
+
+	if c.Protocol != "" {
+		if _, err := models.ParseProtocol(c.Protocol); err != nil {
+			return err
+		}
+	}
+	if c.Refresh != "" {
+		if d, err := time.ParseDuration(c.Refresh); err != nil || d < 0 {
+			return fmt.Errorf("invalid refresh: %q", c.Refresh)
+		}
+	}
+	if _, err := parseRoute(c.Proxy); err != nil {
+		return err
+	}
+
+	if c.Options.Pages < 0 || c.Options.Limit < 0 || c.Options.PageSize < 0 {
+		return errors.New("options must not be negative")
+	}
+	return nil
+}
+
+// Build creates the Fetcher described by the entry.
+func (c SourceConfig) Build() (Fetcher, error) {
+	f, err := c.build()
+	if err != nil || (c.Refresh == "" && c.Proxy == "") {
+		return f, err
+	}
+	sf := &sourceFetcher{Fetcher: f, refresh: -1, pool: c.Proxy == RoutePool}
+	if c.Refresh != "" {
+		sf.refresh, _ = time.ParseDuration(c.Refresh)
+	}
+	sf.upstream, _ = parseRoute(c.Proxy)
+	return sf, nil
+}
+
+func (c SourceConfig) build() (Fetcher, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	name := c.Name
+	if name == "" {
+		u, _ := url.Parse(expandURL(c.URL, 1, 0))
+		name = u.Host
+	}
+
+	switch c.Type {
+	case SourceText:
+		protocol, _ := models.ParseProtocol(c.Protocol)
+		return &TextFetcher{URL: c.URL, Protocol: protocol, Source: name}, nil
+	case SourceHTML:
+		return &HTMLFetcher{URL: c.URL, Source: name}, nil
+	case SourceGeonode:
+		pages := c.Options.Pages
+		if pages == 0 {
+			pages = 10
+		}
+		return &GeonodeFetcher{BaseURL: c.URL, Limit: c.Options.Limit, Pages: pages, Source: name}, nil
+	case SourceProxyDB:
+		return &ProxyDBFetcher{BaseURL: c.URL, Source: name}, nil
+	case SourceRegex:
+		protocol, _ := models.ParseProtocol(c.Protocol)
+		protocolMap, _ := c.protocolMap()
+		return &RegexFetcher{
+			URL:         c.URL,
+			Pattern:     c.Options.Pattern,
+			Protocol:    protocol,
+			ProtocolMap: protocolMap,
+			Pages:       c.Options.Pages,
+			FirstPage:   c.Options.FirstPage,
+			PageSize:    c.Options.PageSize,
+			Source:      name,
+		}, nil
+	case SourceTable:
+		return c.table(name)
+	case SourceJSON:
+		return c.json(name)
+	}
+	return nil, fmt.Errorf("unknown type: %q", c.Type)
+}
+
+// table builds the TableFetcher for a table entry.
+func (c SourceConfig) table(name string) (*TableFetcher, error) {
+	protocol, _ := models.ParseProtocol(c.Protocol)
+	f := &TableFetcher{
+		URL:             c.URL,
+		Rows:            c.Options.Rows,
+		DefaultProtocol: protocol,
+		Next:            c.Options.Next,
+		Pages:           c.Options.Pages,
+		FirstPage:       c.Options.FirstPage,
+		PageSize:        c.Options.PageSize,
+		Source:          name,
+	}
+	for field, spec := range c.Options.Fields {
+		switch field {
+		case "ip":
+			f.IP = spec
+		case "port":
+			f.Port = spec
+		case "protocol":
+			f.Protocol = spec
+		case "user":
+			f.User = spec
+		case "pass":
+			f.Pass = spec
+		case "https":
+			f.HTTPS = spec
+		default:
+			return nil, fmt.Errorf("unknown field: %q", field)
+		}
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// sourceFetcher applies a source's refresh interval and proxy to the
+// requests of its fetcher.
+type sourceFetcher struct {
+	Fetcher
+	refresh  time.Duration // negative when unset
+	upstream Upstream
+	pool     bool
+}
+
+func (f *sourceFetcher) Fetch(ctx context.Context, logger Logger, onProxies ProxyCallback) error {
+	if f.refresh >= 0 {
+		ctx = WithRefresh(ctx, f.refresh)
+	}
+	switch {
+	case f.pool && ProxyPool == nil:
+		return errors.New("source routed through the proxy pool, but no pool is configured")
+	case f.pool:
+		ctx = WithUpstream(ctx, ProxyPool)
+	case f.upstream != nil:
+		ctx = WithUpstream(ctx, f.upstream)
+	}
+	return f.Fetcher.Fetch(ctx, logger, onProxies)
+}
+
+// json builds the JSONFetcher for a json entry.
+func (c SourceConfig) json(name string) (*JSONFetcher, error) {
+	protocol, _ := models.ParseProtocol(c.Protocol)
+	protocolMap, err := c.protocolMap()
+	if err != nil {
+		return nil, err
+	}
+	f := &JSONFetcher{
+		URL:             c.URL,
+		DefaultProtocol: protocol,
+		ProtocolMap:     protocolMap,
+		Pagination:      c.Options.Pagination,
+		PageParam:       c.Options.PageParam,
+		FirstPage:       c.Options.FirstPage,
+		Limit:           c.Options.Limit,
+		LimitParam:      c.Options.LimitParam,
+		Next:            c.Options.Next,
+		Pages:           c.Options.Pages,
+		Source:          name,
+	}
+	for field, path := range c.Options.Paths {
+		switch field {
+		case "items":
+			f.Items = path
+		case "ip":
+			f.IP = path
+		case "port":
+			f.Port = path
+		case "protocol":
+			f.Protocol = path
+		case "country":
+			f.Country = path
+		case "anonymity":
+			f.Anonymity = path
+		default:
+			return nil, fmt.Errorf("unknown path: %q", field)
+		}
+	}
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// protocolMap parses the protocol_map option.
+func (c SourceConfig) protocolMap() (map[string]models.Protocol, error) {
+	protocolMap := make(map[string]models.Protocol, len(c.Options.ProtocolMap))
+	for name, value := range c.Options.ProtocolMap {
+		protocol, err := models.ParseProtocol(value)
+		if err != nil {
+			return nil, fmt.Errorf("protocol_map %q: %w", name, err)
+		}
+		protocolMap[name] = protocol
+	}
+	return protocolMap, nil
+}
+
+// ParseSources decodes a YAML or JSON sources document.
+func ParseSources(data []byte) ([]SourceConfig, error) {
+	var file sourcesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	return file.Sources, nil
+}
+
+// DefaultSources returns the built-in source list.
+func DefaultSources() []SourceConfig {
+	sources, err := ParseSources(defaultSources)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in sources: %v", err))
+	}
+	return sources
+}
+
+// LoadSources reads a sources file. An empty path yields the built-in list.
+func LoadSources(path string) ([]SourceConfig, error) {
+	if path == "" {
+		return DefaultSources(), nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := ParseSources(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return sources, nil
+}
+
+// BuildFetchers creates fetchers for every enabled source. Invalid entries
+// are reported together, one error per entry.
+func BuildFetchers(sources []SourceConfig) ([]Fetcher, error) {
+	var fetchers []Fetcher
+	var errs []error
+
+	for i, c := range sources {
+		if !c.IsEnabled() {
+			continue
+		}
+		f, err := c.Build()
+		if err != nil {
+			label := fmt.Sprintf("source #%d", i+1)
+			if c.Name != "" {
+				label += fmt.Sprintf(" (%s)", c.Name)
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", label, err))
+			continue
+		}
+		fetchers = append(fetchers, f)
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if len(fetchers) == 0 {
+		return nil, errors.New("no enabled sources")
+	}
+	return fetchers, nil
+}
+
+// sourceName returns name, or fallback when name is empty.
+func sourceName(name, fallback string) string {
+	if strings.TrimSpace(name) == "" {
+		return fallback
+	}
+	return name
+}
@@ -0,0 +1,357 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"ProxyParserGO/pkg/models"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+// defaultTablePages bounds how many pages are followed through a next-page
+// link when no limit is configured.
+const defaultTablePages = 10
+
+// TableField locates one value in a table row, either with a CSS selector
+// relative to the row or by the header text of its column.
+type TableField struct {
+	Selector string `yaml:"selector" json:"selector"`
+	Column   string `yaml:"column" json:"column"` // used when Selector is empty
+	Attr     string `yaml:"attr" json:"attr"`     // read this attribute instead of the text
+
+	// Transforms are applied in order and may be trim, lower, upper or
+	// protocol, which keeps the protocol named anywhere in the text, such as
+	// http in "HTTPS (elite)"; the result is trimmed either way. Map then
+	// replaces whole values, compared case-insensitively.
+	Transforms []string          `yaml:"transforms" json:"transforms"`
+	Map        map[string]string `yaml:"map" json:"map"`
+}
+
+func (f TableField) isSet() bool {
+	return f.Selector != "" || f.Column != ""
+}
+
+func (f TableField) validate() error {
+	if f.Selector != "" {
+		if _, err := cascadia.Compile(f.Selector); err != nil {
+			return fmt.Errorf("invalid selector %q: %w", f.Selector, err)
+		}
+	}
+	for _, t := range f.Transforms {
+		switch t {
+		case "trim", "lower", "upper", "protocol":
+		default:
+			return fmt.Errorf("unknown transform: %q", t)
+		}
+	}
+	return nil
+}
+
+// value extracts the field from row. columns maps lower-cased header text
+// to column index.
+func (f TableField) value(row *goquery.Selection, columns map[string]int) string {
+	var cell *goquery.Selection
+	switch {
+	case f.Selector != "":
+		cell = row.Find(f.Selector).First()
+	case f.Column != "":
+		i, ok := columns[strings.ToLower(strings.TrimSpace(f.Column))]
+		if !ok {
+			return ""
+		}
+		cell = row.Children().Filter("td, th").Eq(i)
+	default:
+		return ""
+	}
+
+	v := cell.Text()
+	if f.Attr != "" {
+		v = cell.AttrOr(f.Attr, "")
+	}
+	for _, t := range f.Transforms {
+		switch t {
+		case "trim":
+			v = strings.TrimSpace(v)
+		case "lower":
+			v = strings.ToLower(v)
+		case "upper":
+			v = strings.ToUpper(v)
+		case "protocol":
+			v = protocolIn(v)
+		}
+	}
+	v = strings.TrimSpace(v)
+
+	for from, to := range f.Map {
+		if strings.EqualFold(from, v) {
+			return to
+		}
+	}
+	return v
+}
+
+// protocolIn returns the first protocol named in s, remote DNS variants
+// before their base, or s unchanged when it names none. "SOCKS4/5" yields
+// socks4.
+func protocolIn(s string) string {
+	lower := strings.ToLower(s)
+	for _, p := range []models.Protocol{models.SOCKS4A, models.SOCKS5H, models.SOCKS4, models.SOCKS5, models.HTTP} {
+		if strings.Contains(lower, string(p)) {
+			return string(p)
+		}
+	}
+	return s
+}
+
+// TableFetcher scrapes proxies from an HTML table. Each element matched by
+// Rows is one proxy, whose fields are located by TableField.
+//
+// Further pages are fetched either from a URL template, as for
+// RegexFetcher, or by following the link matched by Next. Paging stops at
+// the first page without proxies.
+type TableFetcher struct {
+	URL  string
+	Rows string
+
+	IP       TableField
+	Port     TableField
+	Protocol TableField // optional, unknown protocols are skipped
+	User     TableField // optional
+	Pass     TableField // optional
+	HTTPS    TableField // optional, "yes" or "true" reports CONNECT support
+
+	// DefaultProtocol is used when Protocol is unset or yields an empty
+	// value.
+	DefaultProtocol models.Protocol
+
+	Next      string // selector of the next-page link
+	Pages     int    // page limit, default 1 for templates and 10 for Next
+	FirstPage int    // value of {page} on the first page, default 1
+	PageSize  int    // {offset} step between pages
+
+	Source string
+	Client *http.Client // nil uses a default client
+}
+
+// Validate checks the selectors and required fields.
+func (f *TableFetcher) Validate() error {
+	if f.Rows == "" {
+		return errors.New("rows selector is required")
+	}
+	if _, err := cascadia.Compile(f.Rows); err != nil {
+		return fmt.Errorf("invalid rows selector %q: %w", f.Rows, err)
+	}
+	if f.Next != "" {
+		if _, err := cascadia.Compile(f.Next); err != nil {
+			return fmt.Errorf("invalid next selector %q: %w", f.Next, err)
+		}
+	}
+
+	if !f.IP.isSet() || !f.Port.isSet() {
+		return errors.New("ip and port fields are required")
+	}
+	for name, field := range map[string]TableField{
+		"ip": f.IP, "port": f.Port, "protocol": f.Protocol, "user": f.User, "pass": f.Pass, "https": f.HTTPS,
+	} {
+		if err := field.validate(); err != nil {
+			return fmt.Errorf("field %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func (f *TableFetcher) Fetch(ctx context.Context, logger Logger, onProxies ProxyCallback) error {
+	log := func(msg string) {
+		if logger != nil {
+			logger(fmt.Sprintf("[%s] %s", f.Source, msg))
+		}
+	}
+
+	if err := f.Validate(); err != nil {
+		log(fmt.Sprintf("Invalid table config: %v", err))
+		return err
+	}
+
+	template := isTemplate(f.URL)
+	pages := f.Pages
+	if pages < 1 {
+		pages = 1
+		if f.Next != "" && !template {
+			pages = defaultTablePages
+		}
+	}
+	firstPage := f.FirstPage
+	if firstPage == 0 {
+		firstPage = 1
+	}
+
+	client := httpClient(f.Client)
+	pageURL := f.URL
+	visited := make(map[string]bool)
+	total := 0
+
+	for i := 0; i < pages && ctx.Err() == nil; i++ {
+		if template {
+			pageURL = expandURL(f.URL, firstPage+i, i*f.PageSize)
+		}
+		visited[pageURL] = true
+
+		doc, err := fetchDocument(ctx, client, pageURL)
+		if err != nil {
+			if ctx.Err() == nil {
+				log(fmt.Sprintf("Error fetching %s: %v", pageURL, err))
+			}
+			break
+		}
+
+		proxies, skipped := f.extract(doc)
+		if skipped > 0 {
+			log(fmt.Sprintf("Skipped %d rows with an unknown protocol or invalid address", skipped))
+		}
+		if len(proxies) == 0 {
+			break
+		}
+		if onProxies != nil {
+			onProxies(proxies)
+		}
+		total += len(proxies)
+
+		if !template {
+			next, ok := f.nextPage(doc)
+			if !ok || visited[next] {
+				break
+			}
+			pageURL = next
+		}
+
+		if pages > 1 {
+			log(fmt.Sprintf("Fetched %d proxies from page %d", len(proxies), i+1))
+			if i < pages-1 && sleepContext(ctx, 500*time.Millisecond) != nil {
+				break
+			}
+		}
+	}
+
+	log(fmt.Sprintf("Fetched %d proxies", total))
+	return ctx.Err()
+}
+
+// extract returns the proxies in doc and how many rows were rejected. Rows
+// with no IP, such as separators and "nothing found" notices, are ignored.
+func (f *TableFetcher) extract(doc *goquery.Document) (proxies []models.Proxy, skipped int) {
+	headers := make(map[*html.Node]map[string]int)
+	tableColumns := func(row *goquery.Selection) map[string]int {
+		table := row.Closest("table")
+		node := table.Get(0)
+		if columns, ok := headers[node]; ok {
+			return columns
+		}
+		columns := make(map[string]int)
+		table.Find("tr").FilterFunction(func(_ int, tr *goquery.Selection) bool {
+			return tr.Children().Filter("th").Length() > 0
+		}).First().Children().Each(func(i int, cell *goquery.Selection) {
+			columns[strings.ToLower(strings.TrimSpace(cell.Text()))] = i
+		})
+		headers[node] = columns
+		return columns
+	}
+
+	doc.Find(f.Rows).Each(func(_ int, row *goquery.Selection) {
+		columns := tableColumns(row)
+
+		ip := f.IP.value(row, columns)
+		if ip == "" {
+			return
+		}
+		port := f.Port.value(row, columns)
+		if !validAddress(ip, port) {
+			skipped++
+			return
+		}
+
+		protocol := f.DefaultProtocol
+		if protocol == "" {
+			protocol = models.HTTP
+		}
+		if v := f.Protocol.value(row, columns); v != "" {
+			p, err := models.ParseProtocol(v)
+			if err != nil {
+				skipped++
+				return
+			}
+			protocol = p
+		}
+
+		p := models.Proxy{
+			IP:       ip,
+			Port:     port,
+			Protocol: protocol,
+			Source:   f.Source,
+			Username: f.User.value(row, columns),
+			Password: f.Pass.value(row, columns),
+		}
+		if protocol == models.HTTP && isYes(f.HTTPS.value(row, columns)) {
+			p.Capabilities = []models.Capability{models.CapHTTPS}
+		}
+		proxies = append(proxies, p)
+	})
+	return proxies, skipped
+}
+
+// isYes reports whether a table cell holds a yes/no flag set to yes.
+func isYes(v string) bool {
+	switch strings.ToLower(v) {
+	case "yes", "y", "true", "1", "+":
+		return true
+	}
+	return false
+}
+
+// nextPage resolves the next-page link, if any.
+func (f *TableFetcher) nextPage(doc *goquery.Document) (string, bool) {
+	if f.Next == "" {
+		return "", false
+	}
+	href, ok := doc.Find(f.Next).First().Attr("href")
+	if !ok || strings.TrimSpace(href) == "" {
+		return "", false
+	}
+	ref, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return "", false
+	}
+	return doc.Url.ResolveReference(ref).String(), true
+}
+
+// fetchDocument downloads and parses an HTML page. The document's Url is
+// the final URL after redirects, for resolving relative links.
+func fetchDocument(ctx context.Context, client *http.Client, pageURL string) (*goquery.Document, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status: %s", resp.Status)
+	}
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	doc.Url = resp.Request.URL
+	return doc, nil
+}
@@ -1,8 +1,9 @@
 package fetcher
 
 import (
+	"context"
 	"fmt"
-	"strings"
+	"net/http"
 
 	"ProxyParserGO/pkg/models"
 )
@@ -11,14 +12,15 @@ type TextFetcher struct {
 	URL      string
 	Protocol models.Protocol
 	Source   string
+	Client   *http.Client // nil uses a default client
 }
 
-func (f *TextFetcher) Fetch(logger Logger, onProxies ProxyCallback) error {
+func (f *TextFetcher) Fetch(ctx context.Context, logger Logger, onProxies ProxyCallback) error {
 	if logger != nil {
 		logger(fmt.Sprintf("Fetching text list from %s...", f.Source))
 	}
 
-	lines, err := fetchURL(f.URL)
+	lines, err := fetchURL(ctx, f.Client, f.URL)
 	if err != nil {
 		if logger != nil {
 			logger(fmt.Sprintf("Error fetching %s: %v", f.Source, err))
@@ -28,23 +30,14 @@ func (f *TextFetcher) Fetch(logger Logger, onProxies ProxyCallback) error {
 
 	var proxies []models.Proxy
 	for _, line := range lines {
-		// Clean line
-		line = strings.TrimSpace(line)
-
-		// Strip protocol prefix if present
-		if idx := strings.Index(line, "://"); idx != -1 {
-			line = line[idx+3:]
-		}
-
-		parts := strings.Split(line, ":")
-		if len(parts) >= 2 {
-			proxies = append(proxies, models.Proxy{
-				IP:       parts[0],
-				Port:     parts[1],
-				Protocol: f.Protocol,
-				Source:   f.Source,
-			})
+		// Accepts ip:port, user:pass@ip:port and ip:port:user:pass, with a
+		// scheme overriding the configured protocol
+		p, err := models.ParseProxy(line, f.Protocol)
+		if err != nil {
+			continue
 		}
+		p.Source = f.Source
+		proxies = append(proxies, p)
 	}
 
 	if len(proxies) > 0 && onProxies != nil {
@@ -0,0 +1,156 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"ProxyParserGO/pkg/checker"
+	"ProxyParserGO/pkg/models"
+)
+
+// Values of a source's proxy setting besides a proxy URL.
+const (
+	RouteDirect = "direct" // connect to the source directly (default)
+	RoutePool   = "pool"   // rotate through ProxyPool
+)
+
+// Upstream chooses the proxy a source's requests are sent through and
+// learns which proxies work. *gateway.Pool implements it.
+type Upstream interface {
+	Next() (models.Proxy, error)
+	Success(p models.Proxy, latency time.Duration)
+	Failure(p models.Proxy, err error)
+}
+
+// ProxyPool serves sources routed through RoutePool, typically proxies
+// validated by an earlier run. Set it before fetching.
+var ProxyPool Upstream
+
+type upstreamKey struct{}
+
+// WithUpstream routes requests made with the returned context through u.
+func WithUpstream(ctx context.Context, u Upstream) context.Context {
+	return context.WithValue(ctx, upstreamKey{}, u)
+}
+
+// StaticUpstream sends every request through p.
+func StaticUpstream(p models.Proxy) Upstream {
+	return staticUpstream{p}
+}
+
+type staticUpstream struct {
+	proxy models.Proxy
+}
+
+func (u staticUpstream) Next() (models.Proxy, error)         { return u.proxy, nil }
+func (u staticUpstream) Success(models.Proxy, time.Duration) {}
+func (u staticUpstream) Failure(models.Proxy, error)         {}
+
+// parseRoute validates a source's proxy setting. It returns the upstream
+// for a proxy URL, and nil for direct and pool routes.
+func parseRoute(route string) (Upstream, error) {
+	switch route {
+	case "", RouteDirect, RoutePool:
+		return nil, nil
+	}
+	p, err := models.ParseProxy(route, models.HTTP)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy %q: %w", route, err)
+	}
+	return StaticUpstream(p), nil
+}
+
+// router sends requests through the upstream in their context, if any, and
+// directly otherwise. It keeps one transport per upstream proxy.
+type router struct {
+	direct  http.RoundTripper
+	timeout time.Duration
+
+	mu         sync.Mutex
+	transports map[string]*http.Transport
+}
+
+func (r *router) RoundTrip(req *http.Request) (*http.Response, error) {
+	u, _ := req.Context().Value(upstreamKey{}).(Upstream)
+	if u == nil {
+		return r.direct.RoundTrip(req)
+	}
+
+	p, err := u.Next()
+	if err != nil {
+		return nil, fmt.Errorf("no upstream proxy: %w", err)
+	}
+	t, err := r.transport(p)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := t.RoundTrip(req)
+	if err != nil {
+		// A cancelled fetch says nothing about the proxy
+		if req.Context().Err() == nil {
+			u.Failure(p, err)
+		}
+		return nil, fmt.Errorf("via %s://%s: %w", p.Protocol, p.Address(), err)
+	}
+	if p.Protocol == models.HTTP && proxyError(resp.StatusCode) {
+		u.Failure(p, errors.New(resp.Status))
+	} else {
+		u.Success(p, time.Since(start))
+	}
+	return resp, nil
+}
+
+// proxyError reports whether an HTTP proxy's answer is most likely its own
+// error page rather than the source's response: a refused login, or
+// failing to reach the source. Other proxies cannot answer in HTTP.
+func proxyError(status int) bool {
+	switch status {
+	case http.StatusProxyAuthRequired, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// transport returns the transport reaching the internet through p.
+func (r *router) transport(p models.Proxy) (*http.Transport, error) {
+	key := p.String()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if t, ok := r.transports[key]; ok {
+		return t, nil
+	}
+
+	t := &http.Transport{
+		MaxIdleConnsPerHost: 2,
+		IdleConnTimeout:     30 * time.Second,
+		TLSHandshakeTimeout: r.timeout,
+	}
+	if p.Protocol == models.HTTP {
+		// Plain HTTP is forwarded, HTTPS tunnelled with CONNECT
+		proxyURL := &url.URL{Scheme: "http", Host: p.Address()}
+		if p.HasAuth() {
+			proxyURL.User = url.UserPassword(p.Username, p.Password)
+		}
+		t.Proxy = http.ProxyURL(proxyURL)
+	} else {
+		dialer, err := checker.NewDialer(p, r.timeout)
+		if err != nil {
+			return nil, err
+		}
+		t.DialContext = dialer.DialContext
+	}
+
+	if r.transports == nil {
+		r.transports = make(map[string]*http.Transport)
+	}
+	r.transports[key] = t
+	return t, nil
+}
@@ -0,0 +1,155 @@
+package fetcher_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"ProxyParserGO/pkg/fetcher"
+	"ProxyParserGO/pkg/gateway"
+	"ProxyParserGO/pkg/models"
+	"ProxyParserGO/pkg/proxytest"
+)
+
+// useClient replaces the shared client for the duration of a test.
+func useClient(t *testing.T, c *http.Client) {
+	old := fetcher.DefaultClient
+	fetcher.DefaultClient = c
+	t.Cleanup(func() { fetcher.DefaultClient = old })
+}
+
+func TestSourceProxy(t *testing.T) {
+	target := proxytest.NewTarget(http.StatusOK, "10.0.0.1:8080\n10.0.0.2:8080\n")
+	defer target.Close()
+	useClient(t, fetcher.NewClient(fetcher.ClientOptions{Timeout: 5 * time.Second}))
+
+	for name, newProxy := range map[string]func(proxytest.Options) (*proxytest.Server, error){
+		"http":   proxytest.NewHTTPProxy,
+		"socks4": proxytest.NewSOCKS4Proxy,
+		"socks5": proxytest.NewSOCKS5Proxy,
+	} {
+		t.Run(name, func(t *testing.T) {
+			upstream := proxytest.Start(t, newProxy, proxytest.Options{Username: "user", Password: "pass"})
+			p := upstream.Proxy()
+			p.Username, p.Password = "user", "pass"
+
+			c := fetcher.SourceConfig{Type: fetcher.SourceText, URL: target.URL, Protocol: "http", Proxy: p.String()}
+			f, err := c.Build()
+			if err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+			if got := collect(t, f); len(got) != 2 {
+				t.Errorf("fetched %d proxies, want 2", len(got))
+			}
+			if len(upstream.Targets()) == 0 {
+				t.Error("the source was fetched without going through the proxy")
+			}
+		})
+	}
+}
+
+func TestSourceProxyPool(t *testing.T) {
+	target := proxytest.NewTarget(http.StatusOK, "10.0.0.1:8080\n")
+	defer target.Close()
+	useClient(t, fetcher.NewClient(fetcher.ClientOptions{Timeout: 5 * time.Second, BaseDelay: time.Millisecond}))
+
+	dead := proxytest.Start(t, proxytest.NewSOCKS5Proxy, proxytest.Options{Mode: proxytest.ModeRefuse})
+	good := proxytest.Start(t, proxytest.NewSOCKS5Proxy, proxytest.Options{})
+
+	c := fetcher.SourceConfig{Type: fetcher.SourceText, URL: target.URL, Protocol: "http", Proxy: fetcher.RoutePool}
+	f, err := c.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if err := f.Fetch(context.Background(), nil, nil); err == nil {
+		t.Error("fetching through an unset pool succeeded, want an error")
+	}
+
+	fetcher.ProxyPool = gateway.NewPool([]models.Proxy{dead.Proxy(), good.Proxy()}, gateway.RoundRobin, 1)
+	defer func() { fetcher.ProxyPool = nil }()
+
+	// The dead proxy fails the first attempt and the retry rotates on
+	if got := collect(t, f); len(got) != 1 {
+		t.Errorf("fetched %d proxies, want 1", len(got))
+	}
+	if len(good.Targets()) == 0 {
+		t.Error("the working pool proxy was not used")
+	}
+}
+
+func TestSourceProxyPoolBadGateway(t *testing.T) {
+	target := proxytest.NewTarget(http.StatusOK, "10.0.0.1:8080\n")
+	defer target.Close()
+	useClient(t, fetcher.NewClient(fetcher.ClientOptions{Timeout: 5 * time.Second, BaseDelay: time.Millisecond}))
+
+	// An HTTP proxy answering with its own error page, listing an address
+	// that must not end up among the fetched proxies
+	errorPage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		io.WriteString(w, "<html>Upstream 10.9.9.9:3128 unreachable</html>")
+	}))
+	defer errorPage.Close()
+	u, _ := url.Parse(errorPage.URL)
+	bad := models.Proxy{IP: u.Hostname(), Port: u.Port(), Protocol: models.HTTP}
+	good := proxytest.Start(t, proxytest.NewHTTPProxy, proxytest.Options{})
+
+	pool := gateway.NewPool([]models.Proxy{bad, good.Proxy()}, gateway.RoundRobin, 1)
+	fetcher.ProxyPool = pool
+	defer func() { fetcher.ProxyPool = nil }()
+
+	c := fetcher.SourceConfig{Type: fetcher.SourceText, URL: target.URL, Protocol: "http", Proxy: fetcher.RoutePool}
+	f, err := c.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	got := collect(t, f)
+	if len(got) != 1 || got[0].IP != "10.0.0.1" {
+		t.Errorf("fetched %v, want only 10.0.0.1 from the source", got)
+	}
+	if pool.Len() != 1 {
+		t.Error("the proxy answering 502 was not evicted")
+	}
+	if p, _ := pool.Next(); p.Port != good.Port {
+		t.Errorf("remaining pool proxy %s, want %s", p.Address(), good.Proxy().Address())
+	}
+}
+
+func TestSourceProxyCancel(t *testing.T) {
+	target := proxytest.NewTarget(http.StatusOK, "10.0.0.1:8080\n")
+	defer target.Close()
+	useClient(t, fetcher.NewClient(fetcher.ClientOptions{Timeout: 5 * time.Second, BaseDelay: time.Millisecond}))
+
+	slow := proxytest.Start(t, proxytest.NewSOCKS5Proxy, proxytest.Options{Mode: proxytest.ModeHang})
+	pool := gateway.NewPool([]models.Proxy{slow.Proxy()}, gateway.RoundRobin, 1)
+	fetcher.ProxyPool = pool
+	defer func() { fetcher.ProxyPool = nil }()
+
+	c := fetcher.SourceConfig{Type: fetcher.SourceText, URL: target.URL, Protocol: "http", Proxy: fetcher.RoutePool}
+	f, err := c.Build()
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := f.Fetch(ctx, nil, nil); err == nil {
+		t.Fatal("cancelled fetch succeeded")
+	}
+
+	// Cancelling the fetch is not the proxy's fault
+	if pool.Len() != 1 {
+		t.Error("the upstream was evicted for a cancelled fetch")
+	}
+}
+
+func TestSourceProxyValidation(t *testing.T) {
+	for _, route := range []string{"gopher://10.0.0.1:70", "not a proxy"} {
+		c := fetcher.SourceConfig{Type: fetcher.SourceText, URL: "https://example.com/", Protocol: "http", Proxy: route}
+		if err := c.Validate(); err == nil {
+			t.Errorf("Validate(proxy: %q) succeeded, want an error", route)
+		}
+	}
+}
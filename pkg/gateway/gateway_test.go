@@ -0,0 +1,238 @@
+package gateway_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"ProxyParserGO/pkg/checker"
+	"ProxyParserGO/pkg/gateway"
+	"ProxyParserGO/pkg/models"
+	"ProxyParserGO/pkg/proxytest"
+
+	"golang.org/x/net/proxy"
+)
+
+const body = "hello through the gateway"
+
+func proxies(n int) []models.Proxy {
+	var list []models.Proxy
+	for i := 0; i < n; i++ {
+		list = append(list, models.Proxy{IP: "10.0.0.1", Port: strconv.Itoa(i + 1), Protocol: models.HTTP})
+	}
+	return list
+}
+
+func TestRoundRobin(t *testing.T) {
+	pool := gateway.NewPool(proxies(3), gateway.RoundRobin, 1)
+	var got []string
+	for i := 0; i < 4; i++ {
+		p, err := pool.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, p.Port)
+	}
+	if want := []string{"1", "2", "3", "1"}; !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+func TestRandom(t *testing.T) {
+	pool := gateway.NewPool(proxies(3), gateway.Random, 1)
+	seen := make(map[string]bool)
+	for i := 0; i < 200; i++ {
+		p, err := pool.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[p.Port] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("picked %v, want all three upstreams", seen)
+	}
+}
+
+func TestLeastLatency(t *testing.T) {
+	list := proxies(3)
+	pool := gateway.NewPool(list, gateway.LeastLatency, 1)
+
+	// Unmeasured upstreams come first, so each is tried
+	pool.Success(list[0], 300*time.Millisecond)
+	if p, _ := pool.Next(); p.Port != "2" {
+		t.Errorf("picked %s, want the unmeasured 2", p.Port)
+	}
+	pool.Success(list[1], 100*time.Millisecond)
+	pool.Success(list[2], 200*time.Millisecond)
+	if p, _ := pool.Next(); p.Port != "2" {
+		t.Errorf("picked %s, want the fastest 2", p.Port)
+	}
+
+	// Latency is smoothed rather than replaced
+	pool.Success(list[1], time.Second)
+	if p, _ := pool.Next(); p.Port != "3" {
+		t.Errorf("picked %s after 2 slowed down, want 3", p.Port)
+	}
+}
+
+func TestEviction(t *testing.T) {
+	list := proxies(2)
+	pool := gateway.NewPool(list, gateway.RoundRobin, 2)
+	var evicted []string
+	pool.OnEvict = func(p models.Proxy, err error) { evicted = append(evicted, p.Port) }
+
+	boom := errors.New("boom")
+	pool.Failure(list[0], boom)
+	pool.Success(list[0], time.Millisecond) // resets the streak
+	pool.Failure(list[0], boom)
+	if pool.Len() != 2 {
+		t.Fatalf("len = %d after non-consecutive failures, want 2", pool.Len())
+	}
+	pool.Failure(list[0], boom)
+	if pool.Len() != 1 || !slices.Equal(evicted, []string{"1"}) {
+		t.Fatalf("len = %d, evicted %v, want 1 left and [1] evicted", pool.Len(), evicted)
+	}
+
+	pool.Failure(list[1], boom)
+	pool.Failure(list[1], boom)
+	if _, err := pool.Next(); !errors.Is(err, gateway.ErrEmptyPool) {
+		t.Errorf("Next on an empty pool = %v, want ErrEmptyPool", err)
+	}
+}
+
+// startGateway serves HTTP and SOCKS5 through upstreams, returning the
+// pool, the HTTP proxy URL and the SOCKS5 address.
+func startGateway(t *testing.T, upstreams ...models.Proxy) (*gateway.Pool, *url.URL, string) {
+	t.Helper()
+	pool := gateway.NewPool(upstreams, gateway.RoundRobin, 1)
+	srv := &gateway.Server{Pool: pool, Timeout: 2 * time.Second, Retries: len(upstreams)}
+
+	httpSrv := httptest.NewServer(srv)
+	t.Cleanup(httpSrv.Close)
+	proxyURL, _ := url.Parse(httpSrv.URL)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.ServeSOCKS5(l)
+	t.Cleanup(func() { l.Close() })
+
+	return pool, proxyURL, l.Addr().String()
+}
+
+func get(t *testing.T, transport *http.Transport, target string) {
+	t.Helper()
+	client := &http.Client{Transport: transport, Timeout: 5 * time.Second}
+	resp, err := client.Get(target)
+	if err != nil {
+		t.Fatalf("GET %s: %v", target, err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(data) != body {
+		t.Fatalf("GET %s = %d %q, want 200 %q", target, resp.StatusCode, data, body)
+	}
+}
+
+func TestServer(t *testing.T) {
+	target := proxytest.NewTarget(http.StatusOK, body)
+	defer target.Close()
+
+	upstreams := map[string]func(proxytest.Options) (*proxytest.Server, error){
+		"http":   proxytest.NewHTTPProxy,
+		"socks4": proxytest.NewSOCKS4Proxy,
+		"socks5": proxytest.NewSOCKS5Proxy,
+	}
+	for name, newProxy := range upstreams {
+		t.Run(name, func(t *testing.T) {
+			up := proxytest.Start(t, newProxy, proxytest.Options{})
+			_, proxyURL, socksAddr := startGateway(t, up.Proxy())
+
+			t.Run("forward", func(t *testing.T) {
+				get(t, &http.Transport{Proxy: http.ProxyURL(proxyURL)}, target.URL)
+			})
+			t.Run("connect", func(t *testing.T) {
+				dialer, err := checker.NewDialer(models.Proxy{IP: proxyURL.Hostname(), Port: proxyURL.Port(), Protocol: models.HTTP}, 2*time.Second)
+				if err != nil {
+					t.Fatal(err)
+				}
+				get(t, &http.Transport{DialContext: dialer.DialContext}, target.URL)
+			})
+			t.Run("socks5", func(t *testing.T) {
+				dialer, err := proxy.SOCKS5("tcp", socksAddr, nil, proxy.Direct)
+				if err != nil {
+					t.Fatal(err)
+				}
+				get(t, &http.Transport{DialContext: dialer.(proxy.ContextDialer).DialContext}, target.URL)
+			})
+		})
+	}
+}
+
+func TestServerRetries(t *testing.T) {
+	target := proxytest.NewTarget(http.StatusOK, body)
+	defer target.Close()
+
+	tests := []struct {
+		name string
+		bad  proxytest.Options
+	}{
+		{"dead upstream", proxytest.Options{Mode: proxytest.ModeRefuse}},
+		{"upstream wants auth", proxytest.Options{Username: "u", Password: "p"}},
+	}
+	for _, tt := range tests {
+		for _, mode := range []string{"forward", "connect"} {
+			t.Run(tt.name+" "+mode, func(t *testing.T) {
+				bad := proxytest.Start(t, proxytest.NewHTTPProxy, tt.bad)
+				good := proxytest.Start(t, proxytest.NewHTTPProxy, proxytest.Options{})
+				pool, proxyURL, _ := startGateway(t, bad.Proxy(), good.Proxy())
+
+				transport := &http.Transport{Proxy: http.ProxyURL(proxyURL)}
+				if mode == "connect" {
+					dialer, _ := checker.NewDialer(models.Proxy{IP: proxyURL.Hostname(), Port: proxyURL.Port(), Protocol: models.HTTP}, 2*time.Second)
+					transport = &http.Transport{DialContext: dialer.DialContext}
+				}
+				get(t, transport, target.URL)
+
+				// The failing upstream was tried first and evicted
+				if pool.Len() != 1 {
+					t.Errorf("pool has %d upstreams, want the failing one evicted", pool.Len())
+				}
+				if p, _ := pool.Next(); p.Port != good.Port {
+					t.Errorf("remaining upstream %s, want %s", p.Port, good.Port)
+				}
+			})
+		}
+	}
+}
+
+func TestServerEmptyPool(t *testing.T) {
+	_, proxyURL, socksAddr := startGateway(t)
+
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}, Timeout: 5 * time.Second}
+	resp, err := client.Get("http://192.0.2.1/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("status = %d, want 502", resp.StatusCode)
+	}
+
+	dialer, _ := proxy.SOCKS5("tcp", socksAddr, nil, proxy.Direct)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if conn, err := dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", "192.0.2.1:80"); err == nil {
+		conn.Close()
+		t.Error("SOCKS5 connect succeeded with no upstreams")
+	}
+}
@@ -0,0 +1,158 @@
+package gateway
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+
+	"ProxyParserGO/pkg/models"
+)
+
+// Strategy decides which upstream proxy serves the next connection.
+type Strategy string
+
+const (
+	RoundRobin   Strategy = "round-robin"
+	Random       Strategy = "random"
+	LeastLatency Strategy = "least-latency"
+)
+
+// ErrEmptyPool is returned once every upstream has been evicted.
+var ErrEmptyPool = errors.New("no upstream proxies available")
+
+// ParseStrategy converts a user-supplied strategy name into a Strategy.
+func ParseStrategy(s string) (Strategy, error) {
+	switch st := Strategy(strings.ToLower(strings.TrimSpace(s))); st {
+	case RoundRobin, Random, LeastLatency:
+		return st, nil
+	}
+	return "", fmt.Errorf("unknown strategy: %q", s)
+}
+
+type upstream struct {
+	proxy    models.Proxy
+	failures int
+}
+
+// Pool hands out upstream proxies and evicts those that keep failing.
+type Pool struct {
+	mu          sync.Mutex
+	upstreams   []*upstream
+	strategy    Strategy
+	maxFailures int
+	next        int
+
+	// OnEvict, if set, is called when an upstream is removed from the pool.
+	OnEvict func(p models.Proxy, err error)
+}
+
+// NewPool creates a pool over proxies. An upstream is evicted after
+// maxFailures consecutive failures; values below 1 are treated as 1.
+func NewPool(proxies []models.Proxy, strategy Strategy, maxFailures int) *Pool {
+	if maxFailures < 1 {
+		maxFailures = 1
+	}
+
+	pool := &Pool{strategy: strategy, maxFailures: maxFailures}
+	for _, p := range proxies {
+		pool.upstreams = append(pool.upstreams, &upstream{proxy: p})
+	}
+	return pool
+}
+
+// Len returns the number of upstreams still in the pool.
+func (p *Pool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.upstreams)
+}
+
+// Next picks an upstream according to the pool's strategy.
+func (p *Pool) Next() (models.Proxy, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.upstreams) == 0 {
+		return models.Proxy{}, ErrEmptyPool
+	}
+
+	switch p.strategy {
+	case Random:
+		return p.upstreams[rand.IntN(len(p.upstreams))].proxy, nil
+
+	case LeastLatency:
+		// Unmeasured upstreams report zero latency, so each gets tried once.
+		best := p.upstreams[0]
+		for _, u := range p.upstreams[1:] {
+			if u.proxy.Latency < best.proxy.Latency {
+				best = u
+			}
+		}
+		return best.proxy, nil
+	}
+
+	p.next %= len(p.upstreams)
+	u := p.upstreams[p.next]
+	p.next++
+	return u.proxy, nil
+}
+
+// Success records a working connection through px and how long it took.
+func (p *Pool) Success(px models.Proxy, latency time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	u := p.find(px)
+	if u == nil {
+		return
+	}
+	u.failures = 0
+	if u.proxy.Latency == 0 {
+		u.proxy.Latency = latency
+	} else {
+		// Smooth out single slow or fast connections
+		u.proxy.Latency = (u.proxy.Latency*7 + latency*3) / 10
+	}
+}
+
+// Failure records a failed connection through px, evicting it once it has
+// failed too many times in a row.
+func (p *Pool) Failure(px models.Proxy, err error) {
+	p.mu.Lock()
+
+	u := p.find(px)
+	if u == nil {
+		p.mu.Unlock()
+		return
+	}
+	u.failures++
+	if u.failures < p.maxFailures {
+		p.mu.Unlock()
+		return
+	}
+
+	for i, candidate := range p.upstreams {
+		if candidate == u {
+			p.upstreams = append(p.upstreams[:i], p.upstreams[i+1:]...)
+			break
+		}
+	}
+	onEvict := p.OnEvict
+	p.mu.Unlock()
+
+	if onEvict != nil {
+		onEvict(u.proxy, err)
+	}
+}
+
+func (p *Pool) find(px models.Proxy) *upstream {
+	for _, u := range p.upstreams {
+		if u.proxy.Protocol == px.Protocol && u.proxy.Address() == px.Address() {
+			return u
+		}
+	}
+	return nil
+}
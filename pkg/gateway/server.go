@@ -0,0 +1,250 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+
+	"ProxyParserGO/pkg/checker"
+	"ProxyParserGO/pkg/models"
+)
+
+// Server forwards client connections through proxies drawn from a Pool.
+// It serves HTTP (forward and CONNECT) via ServeHTTP and SOCKS5 via
+// ServeSOCKS5.
+type Server struct {
+	Pool    *Pool
+	Timeout time.Duration // per upstream connection attempt
+	Retries int           // upstreams to try before giving up on a client
+	Logger  func(string)
+}
+
+func (s *Server) log(format string, args ...any) {
+	if s.Logger != nil {
+		s.Logger(fmt.Sprintf(format, args...))
+	}
+}
+
+func (s *Server) attempts() int {
+	if s.Retries < 1 {
+		return 1
+	}
+	return s.Retries
+}
+
+// dial opens a tunnel to addr through the next working upstream.
+func (s *Server) dial(ctx context.Context, addr string) (net.Conn, error) {
+	var lastErr error
+	for i := 0; i < s.attempts(); i++ {
+		p, err := s.Pool.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		dialer, err := checker.NewDialer(p, s.Timeout)
+		if err != nil {
+			s.Pool.Failure(p, err)
+			lastErr = err
+			continue
+		}
+
+		start := time.Now()
+		dialCtx, cancel := context.WithTimeout(ctx, s.Timeout)
+		conn, err := dialer.DialContext(dialCtx, "tcp", addr)
+		cancel()
+		if err != nil {
+			s.Pool.Failure(p, err)
+			lastErr = err
+			continue
+		}
+
+		s.Pool.Success(p, time.Since(start))
+		return conn, nil
+	}
+	return nil, lastErr
+}
+
+// ServeHTTP implements an HTTP forward proxy with CONNECT support.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		s.serveConnect(w, r)
+		return
+	}
+
+	if !r.URL.IsAbs() {
+		http.Error(w, "this is a proxy, requests must use an absolute URL", http.StatusBadRequest)
+		return
+	}
+
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			pr.Out.URL = pr.In.URL
+			pr.Out.Header.Del("Proxy-Authorization")
+			pr.Out.Header.Del("Proxy-Connection")
+		},
+		Transport: &poolTransport{server: s},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			s.log("HTTP %s %s failed: %v", r.Method, r.URL, err)
+			http.Error(w, "upstream proxy error: "+err.Error(), http.StatusBadGateway)
+		},
+	}
+	rp.ServeHTTP(w, r)
+}
+
+func (s *Server) serveConnect(w http.ResponseWriter, r *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "hijacking not supported", http.StatusInternalServerError)
+		return
+	}
+
+	upstreamConn, err := s.dial(r.Context(), r.Host)
+	if err != nil {
+		s.log("CONNECT %s failed: %v", r.Host, err)
+		http.Error(w, "upstream proxy error: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	clientConn, buf, err := hijacker.Hijack()
+	if err != nil {
+		upstreamConn.Close()
+		return
+	}
+
+	if _, err := clientConn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+		clientConn.Close()
+		upstreamConn.Close()
+		return
+	}
+
+	// Forward anything the client sent ahead of the tunnel being ready
+	if n := buf.Reader.Buffered(); n > 0 {
+		data, _ := buf.Reader.Peek(n)
+		upstreamConn.Write(data)
+	}
+
+	relay(clientConn, upstreamConn)
+}
+
+// poolTransport sends plain HTTP requests through pool upstreams. HTTP
+// upstreams receive the request in forward-proxy form; SOCKS upstreams are
+// used to tunnel to the origin.
+type poolTransport struct {
+	server *Server
+}
+
+func (t *poolTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	s := t.server
+
+	// Requests with a body cannot be replayed against another upstream
+	attempts := s.attempts()
+	if req.Body != nil && req.Body != http.NoBody {
+		attempts = 1
+	}
+
+	var lastErr error
+	for i := 0; i < attempts; i++ {
+		p, err := s.Pool.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		transport, err := s.transportFor(p)
+		if err != nil {
+			s.Pool.Failure(p, err)
+			lastErr = err
+			continue
+		}
+
+		start := time.Now()
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			s.Pool.Failure(p, err)
+			lastErr = err
+			continue
+		}
+
+		// An HTTP upstream answers these itself when it cannot serve us
+		if p.Protocol == models.HTTP && (resp.StatusCode == http.StatusProxyAuthRequired || resp.StatusCode == http.StatusBadGateway) {
+			s.Pool.Failure(p, errors.New(resp.Status))
+			if i < attempts-1 {
+				resp.Body.Close()
+				continue
+			}
+			return resp, nil
+		}
+
+		s.Pool.Success(p, time.Since(start))
+		return resp, nil
+	}
+	return nil, lastErr
+}
+
+func (s *Server) transportFor(p models.Proxy) (*http.Transport, error) {
+	transport := &http.Transport{
+		DisableKeepAlives:     true,
+		ResponseHeaderTimeout: s.Timeout,
+		TLSHandshakeTimeout:   s.Timeout,
+	}
+
+	if p.Protocol == models.HTTP {
+		proxyURL := &url.URL{Scheme: "http", Host: net.JoinHostPort(p.IP, p.Port)}
+		if p.HasAuth() {
+			proxyURL.User = url.UserPassword(p.Username, p.Password)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+		transport.DialContext = (&net.Dialer{Timeout: s.Timeout}).DialContext
+		return transport, nil
+	}
+
+	dialer, err := checker.NewDialer(p, s.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	transport.DialContext = dialer.DialContext
+	return transport, nil
+}
+
+// ServeSOCKS5 accepts SOCKS5 clients on l until it is closed.
+func (s *Server) ServeSOCKS5(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handleSOCKS5(conn)
+	}
+}
+
+// relay copies data in both directions until either side is done.
+func relay(a, b net.Conn) {
+	var once sync.Once
+	closeBoth := func() {
+		a.Close()
+		b.Close()
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		io.Copy(a, b)
+		once.Do(closeBoth)
+	}()
+	go func() {
+		defer wg.Done()
+		io.Copy(b, a)
+		once.Do(closeBoth)
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,144 @@
+package gateway
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+// SOCKS5 constants from RFC 1928.
+const (
+	socks5Version = 0x05
+
+	socks5NoAuth       = 0x00
+	socks5NoAcceptable = 0xff
+
+	socks5CmdConnect = 0x01
+
+	socks5AtypIPv4   = 0x01
+	socks5AtypDomain = 0x03
+	socks5AtypIPv6   = 0x04
+
+	socks5Succeeded        = 0x00
+	socks5HostUnreachable  = 0x04
+	socks5CmdNotSupported  = 0x07
+	socks5AtypNotSupported = 0x08
+)
+
+// socks5HandshakeTimeout bounds how long a client may take to send its
+// request.
+const socks5HandshakeTimeout = 30 * time.Second
+
+func (s *Server) handleSOCKS5(conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(socks5HandshakeTimeout))
+
+	addr, err := socks5Handshake(conn)
+	if err != nil {
+		s.log("SOCKS5 handshake from %s failed: %v", conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	// Dialing may try several upstreams, each with its own timeout
+	conn.SetDeadline(time.Time{})
+
+	upstreamConn, err := s.dial(context.Background(), addr)
+	if err != nil {
+		s.log("SOCKS5 CONNECT %s failed: %v", addr, err)
+		socks5Reply(conn, socks5HostUnreachable)
+		conn.Close()
+		return
+	}
+
+	if err := socks5Reply(conn, socks5Succeeded); err != nil {
+		conn.Close()
+		upstreamConn.Close()
+		return
+	}
+
+	relay(conn, upstreamConn)
+}
+
+// socks5Handshake negotiates "no authentication" and reads a CONNECT
+// request, returning the requested host:port.
+func socks5Handshake(conn net.Conn) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", err
+	}
+	if header[0] != socks5Version {
+		return "", fmt.Errorf("unsupported SOCKS version: %d", header[0])
+	}
+
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+
+	noAuth := false
+	for _, m := range methods {
+		if m == socks5NoAuth {
+			noAuth = true
+		}
+	}
+	if !noAuth {
+		conn.Write([]byte{socks5Version, socks5NoAcceptable})
+		return "", errors.New("client does not offer no-auth method")
+	}
+	if _, err := conn.Write([]byte{socks5Version, socks5NoAuth}); err != nil {
+		return "", err
+	}
+
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return "", err
+	}
+	if req[1] != socks5CmdConnect {
+		socks5Reply(conn, socks5CmdNotSupported)
+		return "", fmt.Errorf("unsupported command: %d", req[1])
+	}
+
+	var host string
+	switch req[3] {
+	case socks5AtypIPv4, socks5AtypIPv6:
+		size := net.IPv4len
+		if req[3] == socks5AtypIPv6 {
+			size = net.IPv6len
+		}
+		ip := make([]byte, size)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case socks5AtypDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", err
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", err
+		}
+		host = string(domain)
+	default:
+		socks5Reply(conn, socks5AtypNotSupported)
+		return "", fmt.Errorf("unsupported address type: %d", req[3])
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", err
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+// socks5Reply sends a reply with an unspecified bind address.
+func socks5Reply(conn net.Conn, code byte) error {
+	_, err := conn.Write([]byte{socks5Version, code, 0x00, socks5AtypIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
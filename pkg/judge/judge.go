@@ -0,0 +1,41 @@
+package judge
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+)
+
+// Response is what the judge returns: the address the request came from and
+// the headers it arrived with.
+type Response struct {
+	IP      string      `json:"ip"`
+	Headers http.Header `json:"headers"`
+}
+
+// Handler echoes the client IP and request headers as JSON. Serve it on a
+// publicly reachable address so proxies can connect to it.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			ip = r.RemoteAddr
+		}
+
+		headers := r.Header.Clone()
+		if r.Host != "" {
+			headers.Set("Host", r.Host)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(Response{IP: ip, Headers: headers})
+	})
+}
+
+// ListenAndServe runs a judge server on addr until it fails.
+func ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("/", Handler())
+	return http.ListenAndServe(addr, mux)
+}
@@ -1,6 +1,14 @@
 package models
 
-import "fmt"
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
 
 type Protocol string
 
@@ -8,19 +16,237 @@ const (
 	HTTP   Protocol = "http"
 	SOCKS4 Protocol = "socks4"
 	SOCKS5 Protocol = "socks5"
+
+	// Variants passing hostnames to the proxy to resolve instead of
+	// resolving them locally.
+	SOCKS4A Protocol = "socks4a"
+	SOCKS5H Protocol = "socks5h"
+)
+
+// Base returns the protocol spoken on the wire, mapping the remote DNS
+// variants onto SOCKS4 and SOCKS5.
+func (p Protocol) Base() Protocol {
+	switch p {
+	case SOCKS4A:
+		return SOCKS4
+	case SOCKS5H:
+		return SOCKS5
+	}
+	return p
+}
+
+// RemoteDNS reports whether hostnames are resolved by the proxy.
+func (p Protocol) RemoteDNS() bool {
+	return p == SOCKS4A || p == SOCKS5H
+}
+
+// Variants returns the protocols sharing p's base protocol, local DNS
+// first.
+func (p Protocol) Variants() []Protocol {
+	switch p.Base() {
+	case SOCKS4:
+		return []Protocol{SOCKS4, SOCKS4A}
+	case SOCKS5:
+		return []Protocol{SOCKS5, SOCKS5H}
+	}
+	return []Protocol{p}
+}
+
+// Anonymity describes how much a proxy reveals about its client.
+type Anonymity string
+
+const (
+	AnonymityUnknown     Anonymity = ""
+	AnonymityTransparent Anonymity = "transparent" // real IP is passed on
+	AnonymityAnonymous   Anonymity = "anonymous"   // IP hidden, but proxy headers are added
+	AnonymityElite       Anonymity = "elite"       // no trace of the proxy or client
 )
 
+// ParseAnonymity converts a user-supplied level name into an Anonymity.
+func ParseAnonymity(s string) (Anonymity, error) {
+	switch a := Anonymity(strings.ToLower(strings.TrimSpace(s))); a {
+	case AnonymityTransparent, AnonymityAnonymous, AnonymityElite:
+		return a, nil
+	}
+	return AnonymityUnknown, fmt.Errorf("unknown anonymity level: %q", s)
+}
+
+// Capability is a way an HTTP proxy can be used.
+type Capability string
+
+const (
+	CapForward Capability = "forward" // plain HTTP requests are forwarded
+	CapHTTPS   Capability = "https"   // CONNECT tunnels to port 443
+	CapConnect Capability = "connect" // CONNECT tunnels to other ports
+)
+
+// ParseCapabilities converts a comma-separated list of capability names.
+func ParseCapabilities(s string) ([]Capability, error) {
+	var caps []Capability
+	for _, name := range strings.Split(s, ",") {
+		switch c := Capability(strings.ToLower(strings.TrimSpace(name))); c {
+		case CapForward, CapHTTPS, CapConnect:
+			caps = append(caps, c)
+		default:
+			return nil, fmt.Errorf("unknown capability: %q", name)
+		}
+	}
+	return caps, nil
+}
+
+// ParseProtocol converts a user-supplied protocol name into a Protocol.
+// "https" is accepted as an alias for HTTP.
+func ParseProtocol(s string) (Protocol, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "http", "https":
+		return HTTP, nil
+	case "socks4":
+		return SOCKS4, nil
+	case "socks5":
+		return SOCKS5, nil
+	case "socks4a":
+		return SOCKS4A, nil
+	case "socks5h":
+		return SOCKS5H, nil
+	}
+	return "", fmt.Errorf("unknown protocol: %q", s)
+}
+
 type Proxy struct {
 	IP       string
 	Port     string
 	Protocol Protocol
 	Source   string
+
+	// Credentials for proxies that require authentication. SOCKS4 proxies
+	// use Username as the userid and ignore Password.
+	Username string
+	Password string
+
+	// Country code reported by the source, if any.
+	Country string
+
+	// Variants of Protocol the proxy was found to support, such as socks4
+	// and socks4a, when detection is enabled.
+	Variants []Protocol
+
+	// Capabilities of an HTTP proxy found by probing, when enabled.
+	// Sources may report CapHTTPS, which probing overrides.
+	Capabilities []Capability
+
+	// Results against each check target, when several are configured
+	Targets []TargetResult
+
+	// Filled in by the checker for proxies that passed validation. Sources
+	// may report Anonymity, which the judge overrides when enabled.
+	Latency   time.Duration
+	ExitIP    string
+	Anonymity Anonymity
+}
+
+// TargetResult is how a proxy fared against one check target.
+type TargetResult struct {
+	Target   string        // name of the target
+	Attempts int           // checks made
+	Passed   int           // checks that succeeded
+	Latency  time.Duration // average of the successful checks
+	Failure  string        // kind of the last failure, if any
+}
+
+// Target returns the result for the named target. The second result is
+// false when the proxy was not checked against it.
+func (p Proxy) Target(name string) (TargetResult, bool) {
+	for _, r := range p.Targets {
+		if r.Target == name {
+			return r, true
+		}
+	}
+	return TargetResult{}, false
 }
 
+// String returns the proxy as a URL, including credentials if any.
 func (p Proxy) String() string {
-	return fmt.Sprintf("%s://%s:%s", p.Protocol, p.IP, p.Port)
+	if !p.HasAuth() {
+		return fmt.Sprintf("%s://%s", p.Protocol, p.Address())
+	}
+	return fmt.Sprintf("%s://%s@%s", p.Protocol, p.UserInfo(), p.Address())
 }
 
+// Address returns host:port, with IPv6 hosts in brackets.
 func (p Proxy) Address() string {
-	return fmt.Sprintf("%s:%s", p.IP, p.Port)
+	return net.JoinHostPort(p.IP, p.Port)
+}
+
+// Can reports whether the proxy has every capability in caps.
+func (p Proxy) Can(caps ...Capability) bool {
+	for _, c := range caps {
+		if !slices.Contains(p.Capabilities, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// HasAuth reports whether the proxy carries credentials.
+func (p Proxy) HasAuth() bool {
+	return p.Username != "" || p.Password != ""
+}
+
+// UserInfo returns the escaped "user:pass" part of the proxy URL.
+func (p Proxy) UserInfo() string {
+	if p.Password == "" {
+		return url.User(p.Username).String()
+	}
+	return url.UserPassword(p.Username, p.Password).String()
+}
+
+// ParseProxy parses "ip:port", "scheme://ip:port", either of those with a
+// "user:pass@" prefix, and "ip:port:user:pass". Lines without a scheme get
+// defaultProtocol.
+func ParseProxy(line string, defaultProtocol Protocol) (Proxy, error) {
+	line = strings.TrimSpace(line)
+	protocol := defaultProtocol
+
+	if scheme, rest, ok := strings.Cut(line, "://"); ok {
+		p, err := ParseProtocol(scheme)
+		if err != nil {
+			return Proxy{}, err
+		}
+		protocol = p
+		line = rest
+	}
+	line = strings.TrimSuffix(line, "/")
+
+	var user, pass string
+	if i := strings.LastIndex(line, "@"); i != -1 {
+		user, pass, _ = strings.Cut(line[:i], ":")
+		user, pass = unescape(user), unescape(pass)
+		line = line[i+1:]
+	} else if parts := strings.Split(line, ":"); len(parts) == 4 && !strings.HasPrefix(line, "[") {
+		line = parts[0] + ":" + parts[1]
+		user, pass = parts[2], parts[3]
+	}
+
+	host, port, err := net.SplitHostPort(line)
+	if err != nil {
+		return Proxy{}, fmt.Errorf("invalid proxy address %q: %w", line, err)
+	}
+	if host == "" || port == "" {
+		return Proxy{}, fmt.Errorf("invalid proxy address %q", line)
+	}
+	if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+		return Proxy{}, fmt.Errorf("invalid proxy port %q", port)
+	}
+	if protocol == "" {
+		return Proxy{}, fmt.Errorf("no protocol for %q", line)
+	}
+
+	return Proxy{IP: host, Port: port, Protocol: protocol, Username: user, Password: pass}, nil
+}
+
+func unescape(s string) string {
+	if u, err := url.PathUnescape(s); err == nil {
+		return u
+	}
+	return s
 }
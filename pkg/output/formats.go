@@ -0,0 +1,247 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"ProxyParserGO/pkg/models"
+)
+
+func init() {
+	Register("plain", func(w io.Writer) Writer { return &lineWriter{w: w, format: plainLine} })
+	Register("url", func(w io.Writer) Writer { return &lineWriter{w: w, format: models.Proxy.String} })
+	Register("jsonl", func(w io.Writer) Writer { return &jsonWriter{enc: json.NewEncoder(w)} })
+	Register("csv", func(w io.Writer) Writer { return &csvWriter{w: csv.NewWriter(w)} })
+	Register("proxychains", func(w io.Writer) Writer { return &proxychainsWriter{w: w} })
+	Register("clash", func(w io.Writer) Writer { return &clashWriter{w: w} })
+}
+
+// plainLine writes ip:port, prefixed with user:pass@ when credentials exist.
+func plainLine(p models.Proxy) string {
+	if p.HasAuth() {
+		return p.UserInfo() + "@" + p.Address()
+	}
+	return p.Address()
+}
+
+// lineWriter writes one formatted proxy per line.
+type lineWriter struct {
+	w      io.Writer
+	format func(models.Proxy) string
+}
+
+func (l *lineWriter) Write(p models.Proxy) error {
+	_, err := fmt.Fprintln(l.w, l.format(p))
+	return err
+}
+
+func (l *lineWriter) Close() error { return nil }
+
+// record is the JSON and CSV representation of a proxy.
+type record struct {
+	IP        string   `json:"ip"`
+	Port      string   `json:"port"`
+	Protocol  string   `json:"protocol"`
+	URL       string   `json:"url"`
+	Username  string   `json:"username,omitempty"`
+	Password  string   `json:"password,omitempty"`
+	Source    string   `json:"source,omitempty"`
+	LatencyMs int64    `json:"latency_ms,omitempty"`
+	ExitIP    string   `json:"exit_ip,omitempty"`
+	Anonymity string   `json:"anonymity,omitempty"`
+	Country   string   `json:"country,omitempty"`
+	Variants  []string `json:"variants,omitempty"`
+
+	Capabilities []string       `json:"capabilities,omitempty"`
+	Targets      []targetRecord `json:"targets,omitempty"`
+}
+
+// targetRecord is a proxy's result against one check target.
+type targetRecord struct {
+	Target    string `json:"target"`
+	Attempts  int    `json:"attempts"`
+	Passed    int    `json:"passed"`
+	LatencyMs int64  `json:"latency_ms,omitempty"`
+	Failure   string `json:"failure,omitempty"`
+}
+
+func newRecord(p models.Proxy) record {
+	return record{
+		IP:        p.IP,
+		Port:      p.Port,
+		Protocol:  string(p.Protocol),
+		URL:       p.String(),
+		Username:  p.Username,
+		Password:  p.Password,
+		Source:    p.Source,
+		LatencyMs: p.Latency.Milliseconds(),
+		ExitIP:    p.ExitIP,
+		Anonymity: string(p.Anonymity),
+		Country:   p.Country,
+		Variants:  variantNames(p.Variants),
+
+		Capabilities: capabilityNames(p.Capabilities),
+		Targets:      targetRecords(p.Targets),
+	}
+}
+
+func variantNames(variants []models.Protocol) []string {
+	var names []string
+	for _, v := range variants {
+		names = append(names, string(v))
+	}
+	return names
+}
+
+func capabilityNames(caps []models.Capability) []string {
+	var names []string
+	for _, c := range caps {
+		names = append(names, string(c))
+	}
+	return names
+}
+
+func targetRecords(results []models.TargetResult) []targetRecord {
+	var records []targetRecord
+	for _, r := range results {
+		records = append(records, targetRecord{
+			Target:    r.Target,
+			Attempts:  r.Attempts,
+			Passed:    r.Passed,
+			LatencyMs: r.Latency.Milliseconds(),
+			Failure:   r.Failure,
+		})
+	}
+	return records
+}
+
+// passedTargets returns the names of the targets a proxy passed.
+func passedTargets(records []targetRecord) []string {
+	var names []string
+	for _, r := range records {
+		if r.Passed > 0 {
+			names = append(names, r.Target)
+		}
+	}
+	return names
+}
+
+// jsonWriter writes JSON lines with all known metadata.
+type jsonWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonWriter) Write(p models.Proxy) error {
+	return j.enc.Encode(newRecord(p))
+}
+
+func (j *jsonWriter) Close() error { return nil }
+
+var csvHeader = []string{"ip", "port", "protocol", "url", "username", "password", "source", "latency_ms", "exit_ip", "anonymity", "country", "variants", "capabilities", "targets"}
+
+// csvWriter writes a header row followed by one row per proxy.
+type csvWriter struct {
+	w             *csv.Writer
+	headerWritten bool
+}
+
+func (c *csvWriter) Write(p models.Proxy) error {
+	if !c.headerWritten {
+		c.headerWritten = true
+		if err := c.w.Write(csvHeader); err != nil {
+			return err
+		}
+	}
+
+	r := newRecord(p)
+	row := []string{r.IP, r.Port, r.Protocol, r.URL, r.Username, r.Password, r.Source, strconv.FormatInt(r.LatencyMs, 10), r.ExitIP, r.Anonymity, r.Country, strings.Join(r.Variants, " "), strings.Join(r.Capabilities, " "), strings.Join(passedTargets(r.Targets), " ")}
+	if err := c.w.Write(row); err != nil {
+		return err
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// proxychainsWriter writes a [ProxyList] section for proxychains.conf.
+// Its fields are separated by whitespace, so proxies with whitespace in
+// their credentials are left out.
+type proxychainsWriter struct {
+	w             io.Writer
+	headerWritten bool
+}
+
+func (pc *proxychainsWriter) Write(p models.Proxy) error {
+	if strings.ContainsFunc(p.Username+p.Password, unicode.IsSpace) {
+		return nil
+	}
+	if !pc.headerWritten {
+		pc.headerWritten = true
+		if _, err := fmt.Fprintln(pc.w, "[ProxyList]"); err != nil {
+			return err
+		}
+	}
+	// proxychains has no remote DNS variants; proxy_dns covers them
+	fields := []string{string(p.Protocol.Base()), p.IP, p.Port}
+	if p.HasAuth() {
+		fields = append(fields, p.Username)
+	}
+	if p.Password != "" {
+		fields = append(fields, p.Password)
+	}
+	_, err := fmt.Fprintln(pc.w, strings.Join(fields, " "))
+	return err
+}
+
+func (pc *proxychainsWriter) Close() error { return nil }
+
+// clashWriter writes a Clash "proxies:" block. Clash has no SOCKS4
+// support, so those proxies are left out.
+type clashWriter struct {
+	w             io.Writer
+	headerWritten bool
+}
+
+func (c *clashWriter) Write(p models.Proxy) error {
+	var kind string
+	switch p.Protocol.Base() {
+	case models.HTTP:
+		kind = "http"
+	case models.SOCKS5:
+		kind = "socks5"
+	default:
+		return nil
+	}
+
+	if !c.headerWritten {
+		c.headerWritten = true
+		if _, err := fmt.Fprintln(c.w, "proxies:"); err != nil {
+			return err
+		}
+	}
+
+	port, err := strconv.Atoi(p.Port)
+	if err != nil {
+		return fmt.Errorf("invalid port %q: %w", p.Port, err)
+	}
+
+	name := fmt.Sprintf("%s-%s", kind, p.Address())
+	if _, err := fmt.Fprintf(c.w, "  - name: %q\n    type: %s\n    server: %s\n    port: %d\n", name, kind, p.IP, port); err != nil {
+		return err
+	}
+	if p.HasAuth() {
+		_, err = fmt.Fprintf(c.w, "    username: %q\n    password: %q\n", p.Username, p.Password)
+	}
+	return err
+}
+
+func (c *clashWriter) Close() error { return nil }
@@ -0,0 +1,112 @@
+package output_test
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ProxyParserGO/pkg/models"
+	"ProxyParserGO/pkg/output"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+// proxies covers each protocol, with and without credentials, and the
+// metadata written by the structured formats.
+var proxies = []models.Proxy{
+	{IP: "10.0.0.1", Port: "8080", Protocol: models.HTTP, Source: "text", Latency: 120 * time.Millisecond,
+		Capabilities: []models.Capability{models.CapForward, models.CapHTTPS}},
+	{IP: "10.0.0.2", Port: "3128", Protocol: models.HTTP, Username: "user", Password: `p@ss:"w"`, Country: "DE"},
+	{IP: "10.0.0.3", Port: "1080", Protocol: models.SOCKS4, Username: "id"},
+	{IP: "10.0.0.4", Port: "1080", Protocol: models.SOCKS4A},
+	{IP: "10.0.0.5", Port: "1080", Protocol: models.SOCKS5, Username: "us er", Password: "secret",
+		ExitIP: "203.0.113.7", Anonymity: models.AnonymityElite},
+	{IP: "2001:db8::1", Port: "1080", Protocol: models.SOCKS5H, Variants: []models.Protocol{models.SOCKS5H},
+		Targets: []models.TargetResult{
+			{Target: "shop", Attempts: 2, Passed: 2, Latency: 300 * time.Millisecond},
+			{Target: "search", Attempts: 1, Failure: "content"},
+		}},
+}
+
+func TestFormats(t *testing.T) {
+	for _, format := range output.Formats() {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := output.New(format, nopCloser{&buf})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, p := range proxies {
+				if err := w.Write(p); err != nil {
+					t.Fatalf("Write %s: %v", p, err)
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Close: %v", err)
+			}
+
+			golden := filepath.Join("testdata", format+".golden")
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := buf.String(); got != string(want) {
+				t.Errorf("output differs from %s:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+// Headers are written once, before the first proxy, and not at all
+// without one.
+func TestHeaders(t *testing.T) {
+	tests := []struct {
+		format string
+		header string
+	}{
+		{"csv", "ip,port,protocol,url,username,password,source,latency_ms,exit_ip,anonymity,country,variants,capabilities,targets\n"},
+		{"proxychains", "[ProxyList]\n"},
+		{"clash", "proxies:\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, _ := output.New(tt.format, nopCloser{&buf})
+			w.Close()
+			if buf.Len() != 0 {
+				t.Errorf("empty output = %q, want nothing", buf.String())
+			}
+
+			w, _ = output.New(tt.format, nopCloser{&buf})
+			w.Write(proxies[0])
+			w.Write(proxies[1])
+			w.Close()
+			if !bytes.HasPrefix(buf.Bytes(), []byte(tt.header)) || bytes.Count(buf.Bytes(), []byte(tt.header)) != 1 {
+				t.Errorf("output = %q, want one %q header first", buf.String(), tt.header)
+			}
+		})
+	}
+
+	// Clash has no SOCKS4, so a list of only SOCKS4 proxies stays empty
+	var buf bytes.Buffer
+	w, _ := output.New("clash", nopCloser{&buf})
+	w.Write(proxies[2])
+	w.Write(proxies[3])
+	w.Close()
+	if buf.Len() != 0 {
+		t.Errorf("clash output for socks4 = %q, want nothing", buf.String())
+	}
+}
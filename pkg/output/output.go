@@ -0,0 +1,70 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"ProxyParserGO/pkg/models"
+)
+
+// Writer streams valid proxies to a destination in some file format.
+// Each Write should leave the output usable in case the program is killed.
+type Writer interface {
+	Write(p models.Proxy) error
+	Close() error
+}
+
+// Factory creates a Writer that writes to w.
+type Factory func(w io.Writer) Writer
+
+var (
+	mu        sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+// Register makes a format available to New under name.
+func Register(name string, f Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	factories[name] = f
+}
+
+// Formats returns the registered format names, sorted.
+func Formats() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates a Writer for format. Closing the Writer also closes w.
+func New(format string, w io.WriteCloser) (Writer, error) {
+	mu.RLock()
+	f, ok := factories[format]
+	mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown output format: %q", format)
+	}
+	return &closingWriter{Writer: f(w), closer: w}, nil
+}
+
+// closingWriter closes the underlying destination after the format writer.
+type closingWriter struct {
+	Writer
+	closer io.Closer
+}
+
+func (w *closingWriter) Close() error {
+	err := w.Writer.Close()
+	if cerr := w.closer.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,94 @@
+package proxytest
+
+import (
+	"bufio"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+func serveHTTP(s *Server, conn net.Conn) {
+	r := bufio.NewReader(conn)
+	req, err := http.ReadRequest(r)
+	if err != nil {
+		return
+	}
+
+	reply := func(status int) {
+		resp := &http.Response{
+			StatusCode: status,
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     make(http.Header),
+			Close:      true,
+		}
+		if status == http.StatusProxyAuthRequired {
+			resp.Header.Set("Proxy-Authenticate", `Basic realm="proxytest"`)
+		}
+		resp.Write(conn)
+	}
+
+	if s.opts.Username != "" && !checkBasicAuth(req.Header.Get("Proxy-Authorization"), s.opts.Username, s.opts.Password) {
+		reply(http.StatusProxyAuthRequired)
+		return
+	}
+	if s.opts.Mode == ModeBadReply {
+		reply(http.StatusForbidden)
+		return
+	}
+
+	if req.Method == http.MethodConnect {
+		s.recordTarget(req.Host)
+		if _, port, _ := net.SplitHostPort(req.Host); len(s.opts.ConnectPorts) > 0 && !slices.Contains(s.opts.ConnectPorts, port) {
+			reply(http.StatusForbidden)
+			return
+		}
+		target, err := dialTarget(req.Host)
+		if err != nil {
+			reply(http.StatusBadGateway)
+			return
+		}
+		if _, err := conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n")); err != nil {
+			target.Close()
+			return
+		}
+		relay(conn, r, target)
+		return
+	}
+
+	s.recordTarget(req.URL.Host)
+	if s.opts.NoForward {
+		reply(http.StatusForbidden)
+		return
+	}
+	if s.opts.Mode == ModeWrongStatus {
+		reply(http.StatusServiceUnavailable)
+		return
+	}
+
+	req.RequestURI = ""
+	req.Header.Del("Proxy-Authorization")
+	req.Header.Del("Proxy-Connection")
+	resp, err := (&http.Transport{Proxy: nil, DisableKeepAlives: true}).RoundTrip(req)
+	if err != nil {
+		reply(http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	resp.Close = true
+	resp.Write(conn)
+}
+
+func checkBasicAuth(header, user, pass string) bool {
+	encoded, ok := strings.CutPrefix(header, "Basic ")
+	if !ok {
+		return false
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return false
+	}
+	return string(decoded) == user+":"+pass
+}
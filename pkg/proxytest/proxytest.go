@@ -0,0 +1,254 @@
+// Package proxytest provides in-process proxy servers and targets so the
+// checker can be exercised without touching the internet.
+package proxytest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"ProxyParserGO/pkg/judge"
+	"ProxyParserGO/pkg/models"
+)
+
+// Mode selects how a proxy behaves.
+type Mode int
+
+const (
+	ModeOK          Mode = iota
+	ModeRefuse           // nothing listens on the address
+	ModeHang             // connections are accepted but never answered
+	ModeBadReply         // the handshake is rejected (SOCKS error code, HTTP 403)
+	ModeWrongStatus      // HTTP proxies answer requests themselves with 503
+	ModeRemoteDNS        // SOCKS proxies reject targets given as IP addresses
+)
+
+// Options configures a proxy server.
+type Options struct {
+	Mode Mode
+
+	// When set, clients must authenticate: HTTP Basic proxy auth, SOCKS5
+	// username/password, or the SOCKS4 userid (Password is ignored).
+	Username string
+	Password string
+
+	// Restrictions of HTTP proxies, answered with 403: no forwarding of
+	// plain requests, and CONNECT only to the listed ports when set.
+	NoForward    bool
+	ConnectPorts []string
+}
+
+// Server is a running proxy server.
+type Server struct {
+	Protocol models.Protocol
+	IP       string
+	Port     string
+
+	opts     Options
+	listener net.Listener
+
+	mu      sync.Mutex
+	conns   map[net.Conn]struct{}
+	targets []string
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// Proxy describes the server as a models.Proxy without credentials.
+func (s *Server) Proxy() models.Proxy {
+	return models.Proxy{IP: s.IP, Port: s.Port, Protocol: s.Protocol, Source: "proxytest"}
+}
+
+// Addr returns the host:port the server listens on.
+func (s *Server) Addr() string {
+	return net.JoinHostPort(s.IP, s.Port)
+}
+
+// Targets returns the destinations clients asked the proxy to reach, as
+// the proxy saw them (hostnames stay unresolved for SOCKS4a and SOCKS5).
+func (s *Server) Targets() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.targets...)
+}
+
+// Close stops the server and drops any open connections.
+func (s *Server) Close() {
+	s.mu.Lock()
+	s.closed = true
+	for c := range s.conns {
+		c.Close()
+	}
+	s.mu.Unlock()
+
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	s.wg.Wait()
+}
+
+func (s *Server) recordTarget(addr string) {
+	s.mu.Lock()
+	s.targets = append(s.targets, addr)
+	s.mu.Unlock()
+}
+
+// Factory starts a proxy server, as NewHTTPProxy and its siblings do.
+type Factory func(Options) (*Server, error)
+
+// Start starts a proxy with newProxy for the duration of a test, failing
+// it when the proxy cannot be started.
+func Start(t testing.TB, newProxy Factory, opts Options) *Server {
+	t.Helper()
+	s, err := newProxy(opts)
+	if err != nil {
+		t.Fatalf("starting proxy: %v", err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// NewHTTPProxy starts an HTTP forward proxy that also supports CONNECT.
+func NewHTTPProxy(opts Options) (*Server, error) {
+	return start(models.HTTP, opts, serveHTTP)
+}
+
+// NewSOCKS4Proxy starts a SOCKS4 proxy that also accepts SOCKS4a requests.
+func NewSOCKS4Proxy(opts Options) (*Server, error) {
+	return start(models.SOCKS4, opts, serveSOCKS4)
+}
+
+// NewSOCKS5Proxy starts a SOCKS5 proxy supporting CONNECT.
+func NewSOCKS5Proxy(opts Options) (*Server, error) {
+	return start(models.SOCKS5, opts, serveSOCKS5)
+}
+
+func start(protocol models.Protocol, opts Options, handle func(*Server, net.Conn)) (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	ip, port, _ := net.SplitHostPort(l.Addr().String())
+
+	s := &Server{
+		Protocol: protocol,
+		IP:       ip,
+		Port:     port,
+		opts:     opts,
+		conns:    make(map[net.Conn]struct{}),
+	}
+
+	if opts.Mode == ModeRefuse {
+		// Keep the address but stop listening so dials are refused
+		l.Close()
+		return s, nil
+	}
+
+	s.listener = l
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			if !s.track(conn) {
+				conn.Close()
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer s.untrack(conn)
+
+				if opts.Mode == ModeHang {
+					io.Copy(io.Discard, conn)
+					return
+				}
+				handle(s, conn)
+			}()
+		}
+	}()
+	return s, nil
+}
+
+func (s *Server) track(c net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.conns[c] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(c net.Conn) {
+	c.Close()
+	s.mu.Lock()
+	delete(s.conns, c)
+	s.mu.Unlock()
+}
+
+// dialTarget connects to the destination requested by a client.
+func dialTarget(addr string) (net.Conn, error) {
+	return net.DialTimeout("tcp", addr, 5*time.Second)
+}
+
+// relay copies data both ways until either side closes. Client data is read
+// through r so bytes already buffered during the handshake are not lost.
+func relay(client net.Conn, r *bufio.Reader, target net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(target, r)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, target)
+		done <- struct{}{}
+	}()
+	<-done
+	client.Close()
+	target.Close()
+	<-done
+}
+
+// Target is a local HTTP server that proxies are pointed at.
+type Target struct {
+	*httptest.Server
+	Status int
+	Body   string
+}
+
+// NewTarget starts a target that answers every request with status and
+// body. Close it when done.
+func NewTarget(status int, body string) *Target {
+	t := &Target{Status: status, Body: body}
+	t.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(t.Status)
+		fmt.Fprint(w, t.Body)
+	}))
+	return t
+}
+
+// NewTLSTarget is like NewTarget but serves HTTPS with a self-signed
+// certificate, which clients must be configured to trust.
+func NewTLSTarget(status int, body string) *Target {
+	t := &Target{Status: status, Body: body}
+	t.Server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(t.Status)
+		fmt.Fprint(w, t.Body)
+	}))
+	return t
+}
+
+// NewJudge starts a proxy judge that echoes the client IP and headers.
+func NewJudge() *httptest.Server {
+	return httptest.NewServer(judge.Handler())
+}
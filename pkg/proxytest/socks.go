@@ -0,0 +1,268 @@
+package proxytest
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// SOCKS4 commands and reply codes.
+const (
+	socks4Connect = 1
+	socks4Bind    = 2
+
+	socks4Granted  = 0x5a
+	socks4Rejected = 0x5b
+	socks4BadUser  = 0x5d
+)
+
+func serveSOCKS4(s *Server, conn net.Conn) {
+	r := bufio.NewReader(conn)
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil || header[0] != 4 {
+		return
+	}
+	port := binary.BigEndian.Uint16(header[2:4])
+	ip := net.IP(header[4:8])
+
+	userID, err := readCString(r)
+	if err != nil {
+		return
+	}
+
+	host := ip.String()
+	// SOCKS4a: 0.0.0.x with x != 0 means a hostname follows the userid
+	if ip[0] == 0 && ip[1] == 0 && ip[2] == 0 && ip[3] != 0 {
+		host, err = readCString(r)
+		if err != nil {
+			return
+		}
+	}
+
+	reply := func(code byte) {
+		conn.Write([]byte{0, code, 0, 0, 0, 0, 0, 0})
+	}
+	replyAddr := func(addr net.Addr) {
+		a := addr.(*net.TCPAddr)
+		msg := []byte{0, socks4Granted, 0, 0}
+		binary.BigEndian.PutUint16(msg[2:], uint16(a.Port))
+		conn.Write(append(msg, a.IP.To4()...))
+	}
+
+	if s.opts.Username != "" && userID != s.opts.Username {
+		reply(socks4BadUser)
+		return
+	}
+	if s.opts.Mode == ModeBadReply || rejectIP(s, host) {
+		reply(socks4Rejected)
+		return
+	}
+
+	addr := net.JoinHostPort(host, strconv.Itoa(int(port)))
+	s.recordTarget(addr)
+	switch header[1] {
+	case socks4Connect:
+	case socks4Bind:
+		target, err := acceptPeer(conn, replyAddr)
+		if err != nil {
+			reply(socks4Rejected)
+			return
+		}
+		replyAddr(target.RemoteAddr())
+		relay(conn, r, target)
+		return
+	default:
+		reply(socks4Rejected)
+		return
+	}
+	target, err := dialTarget(addr)
+	if err != nil {
+		reply(socks4Rejected)
+		return
+	}
+	reply(socks4Granted)
+	relay(conn, r, target)
+}
+
+// acceptPeer serves a BIND request: it listens for the peer, announces the
+// address with the first reply and returns the peer's connection. Closing
+// the client connection abandons the wait.
+func acceptPeer(conn net.Conn, announce func(net.Addr)) (net.Conn, error) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	defer l.Close()
+	announce(l.Addr())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// The client sends nothing until the peer connects; a read returning
+		// early means it went away
+		if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+			l.Close()
+		}
+	}()
+	peer, err := l.Accept()
+
+	// Stop the watch without losing client data
+	conn.SetReadDeadline(time.Unix(1, 0))
+	<-done
+	conn.SetReadDeadline(time.Time{})
+	return peer, err
+}
+
+// rejectIP reports whether a proxy in ModeRemoteDNS refuses host.
+func rejectIP(s *Server, host string) bool {
+	return s.opts.Mode == ModeRemoteDNS && net.ParseIP(host) != nil
+}
+
+func readCString(r *bufio.Reader) (string, error) {
+	s, err := r.ReadString(0)
+	if err != nil {
+		return "", err
+	}
+	return s[:len(s)-1], nil
+}
+
+// SOCKS5 constants from RFC 1928 and RFC 1929.
+const (
+	socks5NoAuth       = 0x00
+	socks5UserPass     = 0x02
+	socks5NoAcceptable = 0xff
+
+	socks5Succeeded = 0x00
+	socks5Refused   = 0x05
+	socks5BadCmd    = 0x07
+	socks5BadAtyp   = 0x08
+)
+
+func serveSOCKS5(s *Server, conn net.Conn) {
+	r := bufio.NewReader(conn)
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil || header[0] != 5 {
+		return
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(r, methods); err != nil {
+		return
+	}
+
+	want := byte(socks5NoAuth)
+	if s.opts.Username != "" {
+		want = socks5UserPass
+	}
+	offered := false
+	for _, m := range methods {
+		if m == want {
+			offered = true
+		}
+	}
+	if !offered {
+		conn.Write([]byte{5, socks5NoAcceptable})
+		return
+	}
+	conn.Write([]byte{5, want})
+
+	if want == socks5UserPass {
+		user, pass, err := readUserPass(r)
+		if err != nil {
+			return
+		}
+		if user != s.opts.Username || pass != s.opts.Password {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+	}
+
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(r, req); err != nil {
+		return
+	}
+
+	reply := func(code byte) {
+		conn.Write([]byte{5, code, 0, 1, 0, 0, 0, 0, 0, 0})
+	}
+
+	var host string
+	switch req[3] {
+	case 1, 4:
+		size := net.IPv4len
+		if req[3] == 4 {
+			size = net.IPv6len
+		}
+		ip := make([]byte, size)
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return
+		}
+		host = net.IP(ip).String()
+	case 3:
+		length, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		domain := make([]byte, length)
+		if _, err := io.ReadFull(r, domain); err != nil {
+			return
+		}
+		host = string(domain)
+	default:
+		reply(socks5BadAtyp)
+		return
+	}
+
+	portBytes := make([]byte, 2)
+	if _, err := io.ReadFull(r, portBytes); err != nil {
+		return
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(portBytes))))
+
+	if req[1] != 1 {
+		reply(socks5BadCmd)
+		return
+	}
+	if s.opts.Mode == ModeBadReply || rejectIP(s, host) {
+		reply(socks5Refused)
+		return
+	}
+
+	s.recordTarget(addr)
+	target, err := dialTarget(addr)
+	if err != nil {
+		reply(socks5Refused)
+		return
+	}
+	reply(socks5Succeeded)
+	relay(conn, r, target)
+}
+
+func readUserPass(r *bufio.Reader) (string, string, error) {
+	version, err := r.ReadByte()
+	if err != nil || version != 1 {
+		return "", "", io.ErrUnexpectedEOF
+	}
+	readField := func() (string, error) {
+		n, err := r.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+		return string(b), err
+	}
+	user, err := readField()
+	if err != nil {
+		return "", "", err
+	}
+	pass, err := readField()
+	return user, pass, err
+}
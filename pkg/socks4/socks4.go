@@ -0,0 +1,262 @@
+// Package socks4 implements a SOCKS4 and SOCKS4a client supporting the
+// CONNECT and BIND commands. Dialer satisfies the proxy.Dialer and
+// proxy.ContextDialer interfaces of golang.org/x/net/proxy.
+package socks4
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+const (
+	version = 4
+
+	cmdConnect = 1
+	cmdBind    = 2
+
+	granted = 0x5a
+)
+
+// ReplyError is a request rejected by the proxy, carrying the reply code.
+type ReplyError byte
+
+// Reply codes other than "request granted".
+const (
+	ErrRejected         ReplyError = 0x5b // rejected or failed
+	ErrIdentUnreachable ReplyError = 0x5c // the proxy cannot reach the client's identd
+	ErrIdentMismatch    ReplyError = 0x5d // identd reported a different user ID
+)
+
+func (e ReplyError) Error() string {
+	switch e {
+	case ErrRejected:
+		return "socks4: request rejected or failed"
+	case ErrIdentUnreachable:
+		return "socks4: request rejected, proxy cannot reach identd on the client"
+	case ErrIdentMismatch:
+		return "socks4: request rejected, user ID does not match identd"
+	}
+	return fmt.Sprintf("socks4: unknown reply code 0x%02x", byte(e))
+}
+
+// ErrMalformedReply is returned for replies that are not SOCKS4 replies.
+var ErrMalformedReply = errors.New("socks4: malformed reply")
+
+// Dialer connects to addresses through a SOCKS4 proxy.
+type Dialer struct {
+	ProxyAddr string // host:port of the proxy
+	UserID    string // sent in every request; most proxies ignore it
+
+	// RemoteDNS sends hostnames to the proxy to resolve (SOCKS4a) instead
+	// of resolving them locally. SOCKS4 itself only carries IPv4
+	// addresses.
+	RemoteDNS bool
+
+	// Timeout bounds connecting to the proxy and the handshake, in
+	// addition to any context deadline. Zero means no limit.
+	Timeout time.Duration
+
+	// Forward is used to reach the proxy. Nil means a direct connection.
+	Forward proxy.Dialer
+}
+
+// Dial connects to addr through the proxy.
+func (d *Dialer) Dial(network, addr string) (net.Conn, error) {
+	return d.DialContext(context.Background(), network, addr)
+}
+
+// DialContext connects to addr through the proxy. ctx bounds connecting to
+// the proxy and the handshake; it does not affect the returned connection.
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, _, err := d.request(ctx, network, cmdConnect, addr)
+	return conn, err
+}
+
+// Bind asks the proxy to accept a single connection from addr, the peer
+// expected to connect, as FTP-style protocols need. The proxy's listening
+// address is Binding.Addr; Binding.Accept waits for the peer.
+func (d *Dialer) Bind(ctx context.Context, network, addr string) (*Binding, error) {
+	conn, reply, err := d.request(ctx, network, cmdBind, addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// An unspecified address means the proxy's own
+	if reply.IP.IsUnspecified() {
+		if host, _, err := net.SplitHostPort(d.ProxyAddr); err == nil {
+			if ip := net.ParseIP(host); ip != nil {
+				reply.IP = ip
+			}
+		}
+	}
+	return &Binding{conn: conn, addr: reply}, nil
+}
+
+// Binding is a pending BIND request.
+type Binding struct {
+	conn net.Conn
+	addr *net.TCPAddr
+}
+
+// Addr is the address the proxy listens on for the peer.
+func (b *Binding) Addr() net.Addr {
+	return b.addr
+}
+
+// Accept waits for the peer to connect to Addr and returns the connection
+// relayed to it, along with the peer's address as reported by the proxy.
+// Only ctx bounds the wait. It can only be called once.
+func (b *Binding) Accept(ctx context.Context) (net.Conn, net.Addr, error) {
+	stop := watch(ctx, b.conn)
+	peer, err := readReply(b.conn)
+	if err = stop(err); err != nil {
+		b.conn.Close()
+		return nil, nil, err
+	}
+	return b.conn, peer, nil
+}
+
+// Close abandons the binding or closes the accepted connection.
+func (b *Binding) Close() error {
+	return b.conn.Close()
+}
+
+// request connects to the proxy and sends a command, returning the
+// connection and the address in the proxy's reply.
+func (d *Dialer) request(ctx context.Context, network string, cmd byte, addr string) (net.Conn, *net.TCPAddr, error) {
+	switch network {
+	case "tcp", "tcp4":
+	default:
+		return nil, nil, fmt.Errorf("socks4: network %q not supported", network)
+	}
+
+	req, err := d.buildRequest(ctx, cmd, addr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if d.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.Timeout)
+		defer cancel()
+	}
+	conn, err := d.dialProxy(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stop := watch(ctx, conn)
+	_, err = conn.Write(req)
+	var reply *net.TCPAddr
+	if err == nil {
+		reply, err = readReply(conn)
+	}
+	if err = stop(err); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, reply, nil
+}
+
+// buildRequest encodes a request for addr, resolving its host unless it
+// is left to the proxy.
+func (d *Dialer) buildRequest(ctx context.Context, cmd byte, addr string) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("socks4: invalid port %q", portStr)
+	}
+
+	// SOCKS4a marks a hostname with the invalid address 0.0.0.x, x != 0
+	ip4 := net.IPv4(0, 0, 0, 1).To4()
+	ip := net.ParseIP(host)
+	if ip == nil && !d.RemoteDNS {
+		ips, err := net.DefaultResolver.LookupIP(ctx, "ip4", host)
+		if err != nil {
+			return nil, fmt.Errorf("socks4: resolving %s: %w", host, err)
+		}
+		ip = ips[0]
+	}
+	if ip != nil {
+		if ip4 = ip.To4(); ip4 == nil {
+			return nil, fmt.Errorf("socks4: %s is not an IPv4 address", host)
+		}
+	}
+
+	req := []byte{version, cmd, 0, 0}
+	binary.BigEndian.PutUint16(req[2:], uint16(port))
+	req = append(req, ip4...)
+	req = append(req, d.UserID...)
+	req = append(req, 0)
+	if ip == nil {
+		req = append(req, host...)
+		req = append(req, 0)
+	}
+	return req, nil
+}
+
+func (d *Dialer) dialProxy(ctx context.Context) (net.Conn, error) {
+	if d.Forward == nil {
+		var nd net.Dialer
+		return nd.DialContext(ctx, "tcp", d.ProxyAddr)
+	}
+	if cd, ok := d.Forward.(proxy.ContextDialer); ok {
+		return cd.DialContext(ctx, "tcp", d.ProxyAddr)
+	}
+	return d.Forward.Dial("tcp", d.ProxyAddr)
+}
+
+// readReply reads an 8-byte reply and returns the address it carries.
+func readReply(conn net.Conn) (*net.TCPAddr, error) {
+	reply := make([]byte, 8)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, ErrMalformedReply
+		}
+		return nil, err
+	}
+	if reply[0] != 0 {
+		return nil, ErrMalformedReply
+	}
+	if reply[1] != granted {
+		return nil, ReplyError(reply[1])
+	}
+	return &net.TCPAddr{
+		IP:   net.IP(append([]byte(nil), reply[4:8]...)),
+		Port: int(binary.BigEndian.Uint16(reply[2:4])),
+	}, nil
+}
+
+// watch makes blocking I/O on conn return once ctx is done. The returned
+// function ends the watch and, given the error of that I/O, reports ctx's
+// error instead when ctx interrupted it.
+//
+// The connection's deadline is only set once ctx is done: one copied from
+// ctx's deadline could expire before ctx reports it.
+func watch(ctx context.Context, conn net.Conn) func(error) error {
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+	return func(err error) error {
+		if !stop() && err == nil {
+			// The deadline may be set after it is cleared below
+			err = ctx.Err()
+		}
+		conn.SetDeadline(time.Time{})
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+}
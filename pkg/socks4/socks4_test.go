@@ -0,0 +1,203 @@
+package socks4_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"ProxyParserGO/pkg/proxytest"
+	"ProxyParserGO/pkg/socks4"
+
+	"golang.org/x/net/proxy"
+)
+
+var (
+	_ proxy.Dialer        = (*socks4.Dialer)(nil)
+	_ proxy.ContextDialer = (*socks4.Dialer)(nil)
+)
+
+// startEcho starts a server that echoes one line back on each connection.
+func startEcho(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				line, _ := bufio.NewReader(conn).ReadString('\n')
+				io.WriteString(conn, line)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func roundTrip(t *testing.T, conn net.Conn) {
+	t.Helper()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.WriteString(conn, "ping\n"); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Fatalf("read %q, %v", line, err)
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	echo := startEcho(t)
+	_, port, _ := net.SplitHostPort(echo)
+
+	tests := []struct {
+		name      string
+		addr      string
+		remoteDNS bool
+		want      string // target seen by the proxy
+	}{
+		{name: "ip", addr: echo, want: echo},
+		{name: "local dns", addr: net.JoinHostPort("localhost", port), want: echo},
+		{name: "remote dns", addr: net.JoinHostPort("localhost", port), remoteDNS: true, want: net.JoinHostPort("localhost", port)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := proxytest.Start(t, proxytest.NewSOCKS4Proxy, proxytest.Options{})
+			d := &socks4.Dialer{ProxyAddr: s.Addr(), RemoteDNS: tt.remoteDNS, Timeout: 5 * time.Second}
+
+			conn, err := d.DialContext(context.Background(), "tcp", tt.addr)
+			if err != nil {
+				t.Fatalf("DialContext: %v", err)
+			}
+			defer conn.Close()
+			roundTrip(t, conn)
+
+			if got := s.Targets(); len(got) != 1 || got[0] != tt.want {
+				t.Errorf("proxy saw %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeProxy answers every request with reply, written one byte at a time.
+func fakeProxy(t *testing.T, reply []byte) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				r.Discard(8)
+				r.ReadString(0)
+				for _, b := range reply {
+					conn.Write([]byte{b})
+					time.Sleep(5 * time.Millisecond)
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestReplies(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply []byte
+		want  error
+	}{
+		{name: "granted", reply: []byte{0, 0x5a, 0, 80, 1, 2, 3, 4}},
+		{name: "rejected", reply: []byte{0, 0x5b, 0, 0, 0, 0, 0, 0}, want: socks4.ErrRejected},
+		{name: "ident unreachable", reply: []byte{0, 0x5c, 0, 0, 0, 0, 0, 0}, want: socks4.ErrIdentUnreachable},
+		{name: "ident mismatch", reply: []byte{0, 0x5d, 0, 0, 0, 0, 0, 0}, want: socks4.ErrIdentMismatch},
+		{name: "unknown code", reply: []byte{0, 0x42, 0, 0, 0, 0, 0, 0}, want: socks4.ReplyError(0x42)},
+		{name: "bad version", reply: []byte{5, 0x5a, 0, 0, 0, 0, 0, 0}, want: socks4.ErrMalformedReply},
+		{name: "short", reply: []byte{0, 0x5a, 0}, want: socks4.ErrMalformedReply},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &socks4.Dialer{ProxyAddr: fakeProxy(t, tt.reply), Timeout: 5 * time.Second}
+			conn, err := d.Dial("tcp", "192.0.2.1:80")
+			if conn != nil {
+				conn.Close()
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDialCancel(t *testing.T) {
+	s := proxytest.Start(t, proxytest.NewSOCKS4Proxy, proxytest.Options{Mode: proxytest.ModeHang})
+	d := &socks4.Dialer{ProxyAddr: s.Addr()}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := d.DialContext(ctx, "tcp", "192.0.2.1:80")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("cancelled dial took %s", elapsed)
+	}
+
+	d.Timeout = 50 * time.Millisecond
+	if _, err := d.Dial("tcp", "192.0.2.1:80"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestBind(t *testing.T) {
+	s := proxytest.Start(t, proxytest.NewSOCKS4Proxy, proxytest.Options{})
+	d := &socks4.Dialer{ProxyAddr: s.Addr(), Timeout: 5 * time.Second}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	b, err := d.Bind(ctx, "tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Bind: %v", err)
+	}
+	defer b.Close()
+
+	// The peer connects to the announced address and echoes a line
+	peer, err := net.Dial("tcp", b.Addr().String())
+	if err != nil {
+		t.Fatalf("peer dial: %v", err)
+	}
+	defer peer.Close()
+	go func() {
+		line, _ := bufio.NewReader(peer).ReadString('\n')
+		io.WriteString(peer, line)
+	}()
+
+	conn, from, err := b.Accept(ctx)
+	if err != nil {
+		t.Fatalf("Accept: %v", err)
+	}
+	if from.String() != peer.LocalAddr().String() {
+		t.Errorf("peer address %s, want %s", from, peer.LocalAddr())
+	}
+	roundTrip(t, conn)
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"math"
+	"time"
+
+	"ProxyParserGO/pkg/models"
+)
+
+// ScoreHalfLife is the age at which a check counts half as much towards
+// Score as a check made now.
+const ScoreHalfLife = 24 * time.Hour
+
+// MaxChecks bounds the check history kept per proxy. Older checks are
+// dropped first; by then they barely count towards Score.
+const MaxChecks = 200
+
+// Check is the outcome of a single check of a proxy.
+type Check struct {
+	Time    time.Time     `json:"time"`
+	OK      bool          `json:"ok"`
+	Latency time.Duration `json:"latency"`
+	Failure string        `json:"failure,omitempty"`
+}
+
+// Record is everything the store knows about one proxy.
+type Record struct {
+	Proxy     models.Proxy `json:"proxy"`
+	FirstSeen time.Time    `json:"first_seen"`
+	LastSeen  time.Time    `json:"last_seen"`
+	Sources   []string     `json:"sources,omitempty"`
+	Checks    []Check      `json:"checks,omitempty"`
+}
+
+// LastCheck returns the most recent check, if any.
+func (r Record) LastCheck() (Check, bool) {
+	if len(r.Checks) == 0 {
+		return Check{}, false
+	}
+	return r.Checks[len(r.Checks)-1], true
+}
+
+// LastActive returns when the proxy was last seen in a source or checked.
+func (r Record) LastActive() time.Time {
+	if c, ok := r.LastCheck(); ok && c.Time.After(r.LastSeen) {
+		return c.Time
+	}
+	return r.LastSeen
+}
+
+// Uptime returns the fraction of checks that succeeded, or 0 when the
+// proxy was never checked.
+func (r Record) Uptime() float64 {
+	if len(r.Checks) == 0 {
+		return 0
+	}
+	ok := 0
+	for _, c := range r.Checks {
+		if c.OK {
+			ok++
+		}
+	}
+	return float64(ok) / float64(len(r.Checks))
+}
+
+// AvgLatency returns the mean latency of successful checks.
+func (r Record) AvgLatency() time.Duration {
+	var total time.Duration
+	n := 0
+	for _, c := range r.Checks {
+		if c.OK {
+			total += c.Latency
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	return total / time.Duration(n)
+}
+
+// Score rates how reliable the proxy is, from 0 to 1. Each check is
+// weighted by its age (see ScoreHalfLife) and the result is the lower
+// bound of the Wilson score interval of the weighted success ratio, so a
+// proxy that passed 1 of 1 checks ranks below one that passed 95 of 100.
+func (r Record) Score(now time.Time) float64 {
+	var ok, total float64
+	for _, c := range r.Checks {
+		age := now.Sub(c.Time)
+		if age < 0 {
+			age = 0
+		}
+		w := math.Pow(0.5, float64(age)/float64(ScoreHalfLife))
+		total += w
+		if c.OK {
+			ok += w
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+
+	const z = 1.96 // 95% confidence
+	p := ok / total
+	denom := 1 + z*z/total
+	centre := p + z*z/(2*total)
+	margin := z * math.Sqrt(p*(1-p)/total+z*z/(4*total*total))
+	return math.Max(0, (centre-margin)/denom)
+}
+
+// addSource records src on the entry unless it is already listed.
+func (r *Record) addSource(src string) {
+	if src == "" {
+		return
+	}
+	for _, s := range r.Sources {
+		if s == src {
+			return
+		}
+	}
+	r.Sources = append(r.Sources, src)
+}
+
+// mergeTargets replaces the stored results for the targets in results and
+// keeps those for other targets.
+func (r *Record) mergeTargets(results []models.TargetResult) {
+	for _, res := range results {
+		replaced := false
+		for i, old := range r.Proxy.Targets {
+			if old.Target == res.Target {
+				r.Proxy.Targets[i] = res
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			r.Proxy.Targets = append(r.Proxy.Targets, res)
+		}
+	}
+}
+
+// WorksFor reports whether the last check against the named target
+// succeeded at least once.
+func (r Record) WorksFor(target string) bool {
+	res, ok := r.Proxy.Target(target)
+	return ok && res.Passed > 0
+}
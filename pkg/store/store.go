@@ -0,0 +1,238 @@
+// Package store keeps a persistent, single-file database of every proxy
+// seen, with its check history, so results accumulate across runs.
+package store
+
+import (
+	"encoding/json"
+	"net"
+	"sort"
+	"time"
+
+	"ProxyParserGO/pkg/models"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var proxiesBucket = []byte("proxies")
+
+// Store is a proxy database backed by a bbolt file. It is safe for
+// concurrent use.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens the database at path, creating it if needed. Only one process
+// may hold it open at a time.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(proxiesBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close closes the database file.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Key identifies a proxy in the store: its base protocol and address. The
+// DNS variants of a SOCKS protocol share a record, as checks may switch a
+// proxy between them.
+func Key(p models.Proxy) string {
+	return string(p.Protocol.Base()) + "://" + net.JoinHostPort(p.IP, p.Port)
+}
+
+// AddSeen records that proxies were found in a source at the given time.
+func (s *Store) AddSeen(proxies []models.Proxy, at time.Time) error {
+	if len(proxies) == 0 {
+		return nil
+	}
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		b := tx.Bucket(proxiesBucket)
+		for _, p := range proxies {
+			r, err := load(b, p)
+			if err != nil {
+				return err
+			}
+			if r.FirstSeen.IsZero() {
+				r.FirstSeen = at
+			}
+			r.LastSeen = at
+			r.addSource(p.Source)
+			if p.Country != "" {
+				r.Proxy.Country = p.Country
+			}
+			if err := save(b, r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AddCheck appends a check result to the history of p, dropping the oldest
+// beyond MaxChecks. Details learnt by a successful check, such as the exit
+// IP or the protocol variant that worked, replace the stored ones, and the
+// results against each target checked replace those for the same target.
+func (s *Store) AddCheck(p models.Proxy, c Check) error {
+	return s.db.Batch(func(tx *bolt.Tx) error {
+		b := tx.Bucket(proxiesBucket)
+		r, err := load(b, p)
+		if err != nil {
+			return err
+		}
+		if r.FirstSeen.IsZero() {
+			r.FirstSeen = c.Time
+			r.LastSeen = c.Time
+		}
+		if c.OK {
+			r.Proxy.Protocol = p.Protocol
+			r.Proxy.ExitIP = p.ExitIP
+			r.Proxy.Anonymity = p.Anonymity
+			if len(p.Variants) > 0 {
+				r.Proxy.Variants = p.Variants
+			}
+			if len(p.Capabilities) > 0 {
+				r.Proxy.Capabilities = p.Capabilities
+			}
+		}
+		r.mergeTargets(p.Targets)
+		r.addSource(p.Source)
+		r.Checks = append(r.Checks, c)
+		if n := len(r.Checks); n > MaxChecks {
+			r.Checks = r.Checks[n-MaxChecks:]
+		}
+		return save(b, r)
+	})
+}
+
+// Get returns the record for p. The second result is false when the proxy
+// is not in the store.
+func (s *Store) Get(p models.Proxy) (Record, bool, error) {
+	var r Record
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(proxiesBucket).Get([]byte(Key(p)))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &r)
+	})
+	return r, found, err
+}
+
+// All returns every record, ordered by key.
+func (s *Store) All() ([]Record, error) {
+	var records []Record
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(proxiesBucket).ForEach(func(_, data []byte) error {
+			var r Record
+			if err := json.Unmarshal(data, &r); err != nil {
+				return err
+			}
+			records = append(records, r)
+			return nil
+		})
+	})
+	return records, err
+}
+
+// Prune deletes proxies that have been neither seen nor checked since
+// cutoff and returns how many were removed.
+func (s *Store) Prune(cutoff time.Time) (int, error) {
+	removed := 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(proxiesBucket)
+		var stale [][]byte
+		err := b.ForEach(func(k, data []byte) error {
+			var r Record
+			if err := json.Unmarshal(data, &r); err != nil {
+				return err
+			}
+			if r.LastActive().Before(cutoff) {
+				stale = append(stale, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range stale {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		removed = len(stale)
+		return nil
+	})
+	return removed, err
+}
+
+// Top returns up to n records with the best Score at now, best first. A
+// non-positive n returns all records.
+func Top(records []Record, n int, now time.Time) []Record {
+	type scored struct {
+		r     Record
+		score float64
+	}
+	ranked := make([]scored, len(records))
+	for i, r := range records {
+		ranked[i] = scored{r, r.Score(now)}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].score > ranked[j].score
+	})
+	if n > 0 && len(ranked) > n {
+		ranked = ranked[:n]
+	}
+
+	top := make([]Record, len(ranked))
+	for i, s := range ranked {
+		top[i] = s.r
+	}
+	return top
+}
+
+// load reads the record for p, or returns a fresh one with the proxy's
+// identity when it is not stored yet.
+func load(b *bolt.Bucket, p models.Proxy) (Record, error) {
+	var r Record
+	if data := b.Get([]byte(Key(p))); data != nil {
+		if err := json.Unmarshal(data, &r); err != nil {
+			return r, err
+		}
+		// Newer credentials win, the address and base protocol are the key
+		if p.HasAuth() {
+			r.Proxy.Username, r.Proxy.Password = p.Username, p.Password
+		}
+		return r, nil
+	}
+
+	r.Proxy = models.Proxy{
+		IP:       p.IP,
+		Port:     p.Port,
+		Protocol: p.Protocol,
+		Source:   p.Source,
+		Username: p.Username,
+		Password: p.Password,
+	}
+	return r, nil
+}
+
+func save(b *bolt.Bucket, r Record) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	return b.Put([]byte(Key(r.Proxy)), data)
+}
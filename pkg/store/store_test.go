@@ -0,0 +1,229 @@
+package store_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"ProxyParserGO/pkg/models"
+	"ProxyParserGO/pkg/store"
+)
+
+func openStore(t *testing.T) *store.Store {
+	t.Helper()
+	s, err := store.Open(filepath.Join(t.TempDir(), "proxies.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestStoreHistory(t *testing.T) {
+	s := openStore(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	p := models.Proxy{IP: "10.0.0.1", Port: "8080", Protocol: models.HTTP, Source: "a"}
+	if err := s.AddSeen([]models.Proxy{p}, start); err != nil {
+		t.Fatalf("AddSeen: %v", err)
+	}
+	p.Source = "b"
+	if err := s.AddSeen([]models.Proxy{p}, start.Add(time.Hour)); err != nil {
+		t.Fatalf("AddSeen: %v", err)
+	}
+
+	checks := []store.Check{
+		{Time: start.Add(time.Hour), OK: true, Latency: 100 * time.Millisecond},
+		{Time: start.Add(2 * time.Hour), OK: false, Failure: "timeout"},
+		{Time: start.Add(3 * time.Hour), OK: true, Latency: 300 * time.Millisecond},
+	}
+	p.ExitIP = "203.0.113.7"
+	for _, c := range checks {
+		if err := s.AddCheck(p, c); err != nil {
+			t.Fatalf("AddCheck: %v", err)
+		}
+	}
+
+	r, found, err := s.Get(p)
+	if err != nil || !found {
+		t.Fatalf("Get = %v, %v", found, err)
+	}
+	if !r.FirstSeen.Equal(start) || !r.LastSeen.Equal(start.Add(time.Hour)) {
+		t.Errorf("seen = %v..%v, want %v..%v", r.FirstSeen, r.LastSeen, start, start.Add(time.Hour))
+	}
+	if len(r.Sources) != 2 || r.Sources[0] != "a" || r.Sources[1] != "b" {
+		t.Errorf("sources = %v, want [a b]", r.Sources)
+	}
+	if len(r.Checks) != 3 {
+		t.Fatalf("got %d checks, want 3", len(r.Checks))
+	}
+	if got := r.Uptime(); got < 0.66 || got > 0.67 {
+		t.Errorf("uptime = %v, want 2/3", got)
+	}
+	if got := r.AvgLatency(); got != 200*time.Millisecond {
+		t.Errorf("avg latency = %v, want 200ms", got)
+	}
+	if r.Proxy.ExitIP != "203.0.113.7" {
+		t.Errorf("exit IP = %q, want 203.0.113.7", r.Proxy.ExitIP)
+	}
+
+	// Same address over another protocol is a separate proxy
+	other := p
+	other.Protocol = models.SOCKS5
+	if _, found, _ := s.Get(other); found {
+		t.Error("socks5 entry found, want only http")
+	}
+}
+
+func TestStoreHistoryLimit(t *testing.T) {
+	s := openStore(t)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	p := models.Proxy{IP: "10.0.0.1", Port: "8080", Protocol: models.HTTP}
+	for i := 0; i < store.MaxChecks+5; i++ {
+		if err := s.AddCheck(p, store.Check{Time: start.Add(time.Duration(i) * time.Minute), OK: true}); err != nil {
+			t.Fatalf("AddCheck: %v", err)
+		}
+	}
+
+	r, _, err := s.Get(p)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(r.Checks) != store.MaxChecks {
+		t.Fatalf("got %d checks, want %d", len(r.Checks), store.MaxChecks)
+	}
+	if first := r.Checks[0].Time; !first.Equal(start.Add(5 * time.Minute)) {
+		t.Errorf("oldest check at %v, want the first 5 dropped", first)
+	}
+	if !r.FirstSeen.Equal(start) {
+		t.Errorf("first seen = %v, want %v", r.FirstSeen, start)
+	}
+}
+
+func TestStoreVariants(t *testing.T) {
+	s := openStore(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	p := models.Proxy{IP: "10.0.0.1", Port: "1080", Protocol: models.SOCKS4, Source: "a"}
+	if err := s.AddSeen([]models.Proxy{p}, now); err != nil {
+		t.Fatalf("AddSeen: %v", err)
+	}
+	// Variant detection found that only remote DNS works
+	p.Protocol = models.SOCKS4A
+	p.Variants = []models.Protocol{models.SOCKS4A}
+	if err := s.AddCheck(p, store.Check{Time: now.Add(time.Hour), OK: true}); err != nil {
+		t.Fatalf("AddCheck: %v", err)
+	}
+
+	records, err := s.All()
+	if err != nil {
+		t.Fatalf("All: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("got %d records, want the sighting and check in one", len(records))
+	}
+	r := records[0]
+	if !r.FirstSeen.Equal(now) || len(r.Checks) != 1 || len(r.Sources) != 1 {
+		t.Errorf("record = %+v, want the sighting with one check", r)
+	}
+	if r.Proxy.Protocol != models.SOCKS4A {
+		t.Errorf("protocol = %s, want the working socks4a", r.Proxy.Protocol)
+	}
+}
+
+func TestStoreTargets(t *testing.T) {
+	s := openStore(t)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	p := models.Proxy{IP: "10.0.0.1", Port: "8080", Protocol: models.HTTP}
+	p.Targets = []models.TargetResult{
+		{Target: "shop", Attempts: 2, Passed: 2},
+		{Target: "search", Attempts: 2, Passed: 0, Failure: "content"},
+	}
+	if err := s.AddCheck(p, store.Check{Time: now, OK: false}); err != nil {
+		t.Fatalf("AddCheck: %v", err)
+	}
+	// A later check of one target leaves the other's result alone
+	p.Targets = []models.TargetResult{{Target: "search", Attempts: 1, Passed: 1}}
+	if err := s.AddCheck(p, store.Check{Time: now.Add(time.Hour), OK: true}); err != nil {
+		t.Fatalf("AddCheck: %v", err)
+	}
+
+	r, _, err := s.Get(p)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(r.Proxy.Targets) != 2 {
+		t.Fatalf("targets = %+v, want shop and search", r.Proxy.Targets)
+	}
+	if !r.WorksFor("shop") || !r.WorksFor("search") || r.WorksFor("other") {
+		t.Errorf("WorksFor shop, search, other = %v, %v, %v, want true, true, false",
+			r.WorksFor("shop"), r.WorksFor("search"), r.WorksFor("other"))
+	}
+}
+
+func TestScore(t *testing.T) {
+	now := time.Date(2024, 1, 10, 0, 0, 0, 0, time.UTC)
+	history := func(ok, failed int, age time.Duration) store.Record {
+		var r store.Record
+		for i := 0; i < ok+failed; i++ {
+			r.Checks = append(r.Checks, store.Check{Time: now.Add(-age), OK: i < ok})
+		}
+		return r
+	}
+
+	tests := []struct {
+		name          string
+		better, worse store.Record
+	}{
+		{"more evidence", history(95, 5, 0), history(1, 0, 0)},
+		{"higher uptime", history(90, 10, 0), history(50, 50, 0)},
+		{"more recent", history(10, 0, time.Hour), history(10, 0, 7*24*time.Hour)},
+		{"checked at all", history(1, 0, 0), history(0, 0, 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, w := tt.better.Score(now), tt.worse.Score(now)
+			if b <= w {
+				t.Errorf("score %v <= %v", b, w)
+			}
+		})
+	}
+
+	top := store.Top([]store.Record{history(1, 1, 0), history(50, 0, 0), history(0, 3, 0)}, 2, now)
+	if len(top) != 2 || len(top[0].Checks) != 50 || len(top[1].Checks) != 2 {
+		t.Errorf("Top returned the wrong records: %d", len(top))
+	}
+}
+
+func TestPrune(t *testing.T) {
+	s := openStore(t)
+	now := time.Now()
+
+	stale := models.Proxy{IP: "10.0.0.1", Port: "1080", Protocol: models.SOCKS5}
+	fresh := models.Proxy{IP: "10.0.0.2", Port: "1080", Protocol: models.SOCKS5}
+	checked := models.Proxy{IP: "10.0.0.3", Port: "1080", Protocol: models.SOCKS5}
+
+	s.AddSeen([]models.Proxy{stale, checked}, now.Add(-30*24*time.Hour))
+	s.AddSeen([]models.Proxy{fresh}, now)
+	s.AddCheck(checked, store.Check{Time: now, OK: true})
+
+	removed, err := s.Prune(now.Add(-7 * 24 * time.Hour))
+	if err != nil || removed != 1 {
+		t.Fatalf("Prune = %d, %v; want 1 removed", removed, err)
+	}
+
+	all, err := s.All()
+	if err != nil {
+		t.Fatalf("All: %v", err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("got %d records after prune, want 2", len(all))
+	}
+	for _, r := range all {
+		if r.Proxy.IP == stale.IP {
+			t.Errorf("stale proxy %s survived prune", stale)
+		}
+	}
+}